@@ -1,89 +1,276 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
 	"log"
+	"math"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/0x800a6/www/internal/handlers"
+	"github.com/0x800a6/www/internal/config"
+	"github.com/0x800a6/www/internal/metrics"
 	"github.com/0x800a6/www/internal/middleware"
 	"github.com/0x800a6/www/internal/models"
+	"github.com/0x800a6/www/internal/templates"
 	"github.com/0x800a6/www/internal/utils"
 )
 
+// Set at build time with -ldflags "-X main.version=... -X main.commit=..."
+var (
+	version = "dev"
+	commit  = ""
+)
+
 func main() {
-	rateLimiterConfig := models.RateLimiterConfig{
-		RequestsPerMinute: 60,
-		BurstSize:         10,
-		WindowSize:        15 * time.Second,
-		CleanupInterval:   5 * time.Minute,
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
 	}
-	rateLimiter := models.NewRateLimiter(rateLimiterConfig)
-	defer rateLimiter.Stop()
+}
 
-	tmplData := models.TemplateData{
-		Site: struct {
-			Name        string
-			Description string
-			Author      string
-			Year        int
-		}{
-			Name:        "Lexi's Website",
-			Description: "Software & Web Developer, Cosplayer, Anime Enthusiast, and Privacy Advocate. Building things on Arch Linux with C, Rust, TypeScript, and more.",
-			Author:      "Lexi Rose Rogers",
-			Year:        time.Now().Year(),
-		},
+// run serves until SIGINT or SIGTERM, returning only after in-flight requests
+// have drained and background goroutines have stopped
+func run(args []string) error {
+	cfg, err := config.Load(args)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
 	}
 
-	sitemapHandler := handlers.NewSitemapHandler("https://lrr.sh")
+	site := &website{started: time.Now()}
 
-	mux := http.NewServeMux()
+	site.limiter, err = newLimiter(cfg)
+	if err != nil {
+		return fmt.Errorf("rate limiter error: %w", err)
+	}
+	defer site.limiter.Stop()
 
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(utils.GetTemplatePath("static/")))))
+	site.escalator = models.NewEscalator(site.limiter, cfg.Escalation())
+	defer site.escalator.Stop()
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.HomeHandler(w, r, tmplData)
-	})
+	// Dev mode serves static/ as it is on disk, so edits show up on reload
+	site.assets = models.NewAssetStore(utils.GetTemplatePath("static"))
+	if !cfg.Server.Dev {
+		if err := site.assets.Load(); err != nil {
+			return fmt.Errorf("asset pipeline error: %w", err)
+		}
+	}
 
-	mux.HandleFunc("/sitemap.xml", sitemapHandler.ServeXML)
-	mux.HandleFunc("/sitemap", func(w http.ResponseWriter, r *http.Request) {
-		sitemapHandler.ServePage(w, r, tmplData)
-	})
+	site.registry = templates.NewRegistry(cfg.Server.Dev, template.FuncMap{"asset": site.assets.URL})
+	site.registry.Watch(time.Second)
+	defer site.registry.Stop()
 
-	mux.HandleFunc("/ratelimit", func(w http.ResponseWriter, r *http.Request) {
-		handlers.RateLimitHandler(w, r, tmplData)
-	})
+	site.catalog, err = models.LoadProjects(utils.GetTemplatePath("data/projects.json"))
+	if err != nil {
+		return fmt.Errorf("projects loading error: %w", err)
+	}
 
-	mux.HandleFunc("/resume", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ResumeHandler(w, r, tmplData)
-	})
+	site.resume, err = models.LoadResume(utils.GetTemplatePath("data/resume.json"))
+	if err != nil {
+		return fmt.Errorf("resume loading error: %w", err)
+	}
 
-	mux.HandleFunc("/projects", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ProjectsHandler(w, r, tmplData)
-	})
+	// Pages also change with the data files, only read at startup, and with
+	// the binary rendering them
+	executable, _ := os.Executable()
+	site.dataModTime = filesModTime(executable, utils.GetTemplatePath("data/projects.json"), utils.GetTemplatePath("data/resume.json"))
 
-	mux.HandleFunc("/changelog", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ChangelogHandler(w, r, tmplData)
-	})
+	site.changelogStore = models.NewChangelogStore(utils.GetChangelogPath())
+	site.changelogStore.Watch(2 * time.Second)
+	defer site.changelogStore.Stop()
 
-	mux.HandleFunc("/changelog.json", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ChangelogAPIHandler(w, r, tmplData)
-	})
+	// Rendered output only changes when templates or the changelog reload
+	site.responseCache = middleware.NewResponseCache(cfg.Server.ResponseCacheBytes)
+	site.registry.OnReload(site.responseCache.Invalidate)
+	site.changelogStore.OnReload(site.responseCache.Invalidate)
 
-	mux.HandleFunc("/changelog.rss", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ChangelogRSSHandler(w, r, tmplData)
+	metrics.NewGaugeFunc("www_ratelimit_active_buckets", "Clients currently tracked by the rate limiter.", func() float64 {
+		stats, err := site.limiter.Stats()
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(stats.Keys)
 	})
-
-	mux.HandleFunc("/changelog.md", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ChangelogMarkdownHandler(w, r, tmplData)
+	metrics.NewGaugeFunc("www_ratelimit_offenders", "Keys with recent rejections or an escalated ban.", func() float64 {
+		return float64(site.escalator.OffenderCount())
+	})
+	metrics.NewGaugeFunc("www_response_cache_entries", "Responses held in the response cache.", func() float64 {
+		return float64(site.responseCache.Len())
 	})
 
-	mux.HandleFunc("/vtuberstv", handlers.VTubersTVProjectsHandler)
+	routes, err := site.routes(cfg)
+	if err != nil {
+		return fmt.Errorf("template parsing error: %w", err)
+	}
+	handler := &reloadableHandler{handler: routes}
+
+	server := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		log.Printf("Server starting on %s", cfg.Server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	// The admin listener is not reloadable, it keeps the token it started with
+	var adminServer *http.Server
+	if cfg.Metrics.Enabled && cfg.Metrics.Addr != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("/metrics", metrics.Handler(cfg.Metrics.Token))
+
+		adminServer = &http.Server{
+			Addr:              cfg.Metrics.Addr,
+			Handler:           adminMux,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+			ReadTimeout:       cfg.Server.ReadTimeout,
+			WriteTimeout:      cfg.Server.WriteTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+		}
+		go func() {
+			log.Printf("Admin server starting on %s", cfg.Metrics.Addr)
+			serveErr <- adminServer.ListenAndServe()
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case err := <-serveErr:
+			return err
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				cfg = site.reload(args, cfg, handler)
+				continue
+			}
+
+			log.Printf("Received %s, shutting down", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+			defer cancel()
+
+			// The limiter state is saved by the deferred Stop, whatever
+			// the shutdown returns
+			servers := []*http.Server{server}
+			if adminServer != nil {
+				servers = append(servers, adminServer)
+			}
+			if err := shutdown(ctx, serveErr, servers...); err != nil {
+				return err
+			}
+			log.Println("Server stopped")
+			return nil
+		}
+	}
+}
+
+// shutdown drains every server at once, so each gets the whole timeout and a
+// failure of one still stops the others, then waits for their Serve calls,
+// which report on serveErr, to return
+func shutdown(ctx context.Context, serveErr <-chan error, servers ...*http.Server) error {
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("shutdown of %s: %w", server.Addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for range servers {
+		if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// revision returns the commit the binary was built from, falling back to the
+// VCS information go build embeds when building inside a checkout
+func revision() string {
+	if commit != "" {
+		return commit
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return ""
+}
+
+// reload re-parses templates, the changelog and the configuration, then swaps
+// in routes built from the new configuration. Any failure keeps the previous
+// state, and settings bound to the listener only apply after a restart.
+func (site *website) reload(args []string, cfg *config.Config, handler *reloadableHandler) *config.Config {
+	log.Println("Received SIGHUP, reloading")
+
+	if !cfg.Server.Dev {
+		if err := site.assets.Load(); err != nil {
+			log.Printf("assets: reload failed: %v", err)
+		}
+	}
+	if err := site.registry.Reload(); err != nil {
+		log.Printf("templates: reload failed: %v", err)
+	}
+	if err := site.changelogStore.Load(); err != nil {
+		log.Printf("changelog: reload failed: %v", err)
+	}
+
+	newCfg, err := config.Load(args)
+	if err != nil {
+		log.Printf("config: reload failed: %v", err)
+		return cfg
+	}
+
+	routes, err := site.routes(newCfg)
+	if err != nil {
+		log.Printf("config: reload failed: %v", err)
+		return cfg
+	}
+
+	if newCfg.Server.Addr != cfg.Server.Addr ||
+		newCfg.Server.Dev != cfg.Server.Dev ||
+		newCfg.Server.ReadHeaderTimeout != cfg.Server.ReadHeaderTimeout ||
+		newCfg.Server.ReadTimeout != cfg.Server.ReadTimeout ||
+		newCfg.Server.WriteTimeout != cfg.Server.WriteTimeout ||
+		newCfg.Server.IdleTimeout != cfg.Server.IdleTimeout ||
+		newCfg.Server.ResponseCacheBytes != cfg.Server.ResponseCacheBytes ||
+		newCfg.Metrics.Addr != cfg.Metrics.Addr ||
+		newCfg.RateLimit.Backend != cfg.RateLimit.Backend ||
+		newCfg.RateLimit.RedisURL != cfg.RateLimit.RedisURL ||
+		newCfg.RateLimit.RedisPrefix != cfg.RateLimit.RedisPrefix ||
+		newCfg.RateLimit.StateFile != cfg.RateLimit.StateFile ||
+		newCfg.RateLimit.StateInterval != cfg.RateLimit.StateInterval {
+		log.Println("config: listener, dev mode, cache size, rate limiter backend and state file changes apply after a restart")
+	}
 
-	mux.HandleFunc("/health", handlers.HealthHandler)
-	handler := middleware.RateLimitMiddleware(rateLimiter)(mux)
-	handler = middleware.ExtraMiddleware(handler)
+	site.limiter.SetConfig(newCfg.RateLimiter())
+	site.escalator.SetConfig(newCfg.Escalation())
+	handler.Set(routes)
+	site.responseCache.Invalidate()
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	log.Println("Reload complete")
+	return newCfg
 }
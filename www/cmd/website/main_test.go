@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestShutdownStopsEveryServer checks that a server failing to drain in time
+// does not keep the others serving
+func TestShutdownStopsEveryServer(t *testing.T) {
+	hung := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	serveErr := make(chan error, 2)
+	start := func(handler http.HandlerFunc) (*http.Server, string) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := &http.Server{Addr: listener.Addr().String(), Handler: handler}
+		go func() { serveErr <- server.Serve(listener) }()
+		return server, "http://" + listener.Addr().String()
+	}
+
+	// The admin server has a request that outlives the shutdown timeout
+	admin, adminURL := start(func(w http.ResponseWriter, r *http.Request) {
+		close(hung)
+		<-release
+	})
+	server, serverURL := start(func(w http.ResponseWriter, r *http.Request) {})
+
+	go http.Get(adminURL)
+	<-hung
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := shutdown(ctx, serveErr, admin, server)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown error %v, want the admin server's timeout", err)
+	}
+
+	client := &http.Client{Timeout: time.Second}
+	if _, err := client.Get(serverURL); err == nil {
+		t.Error("main server still serving after the admin server failed to shut down")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	serveErr := make(chan error, 2)
+	var servers []*http.Server
+	for i := 0; i < 2; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := &http.Server{Addr: listener.Addr().String(), Handler: http.NotFoundHandler()}
+		go func() { serveErr <- server.Serve(listener) }()
+		servers = append(servers, server)
+	}
+
+	if err := shutdown(context.Background(), serveErr, servers...); err != nil {
+		t.Errorf("shutdown error %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0x800a6/www/internal/config"
+	"github.com/0x800a6/www/internal/handlers"
+	"github.com/0x800a6/www/internal/metrics"
+	"github.com/0x800a6/www/internal/middleware"
+	"github.com/0x800a6/www/internal/models"
+	"github.com/0x800a6/www/internal/redis"
+	"github.com/0x800a6/www/internal/templates"
+	"github.com/0x800a6/www/internal/utils"
+)
+
+// website holds the long-lived state that survives a config reload. Routes
+// are rebuilt from it whenever the configuration changes.
+type website struct {
+	started        time.Time
+	dataModTime    time.Time
+	limiter        limiterBackend
+	assets         *models.AssetStore
+	escalator      *models.Escalator
+	registry       *templates.Registry
+	changelogStore *models.ChangelogStore
+	responseCache  *middleware.ResponseCache
+	catalog        *models.ProjectCatalog
+	resume         *models.Resume
+}
+
+// routes builds the complete handler for cfg
+func (site *website) routes(cfg *config.Config) (http.Handler, error) {
+	tmplData := cfg.TemplateData()
+
+	projectsHandler := handlers.NewProjectsHandler(site.catalog)
+	resumeHandler := handlers.NewResumeHandler(site.resume, site.catalog)
+	changelogHandler := handlers.NewChangelogHandler(site.changelogStore, tmplData, cfg.Server.BaseURL)
+	sitemapHandler := handlers.NewSitemapHandler(cfg.Server.BaseURL, site.pagesModTime)
+
+	pagesConditional := middleware.ConditionalMiddleware(site.pagesModTime)
+	changelogConditional := middleware.ConditionalMiddleware(site.changelogStore.ModTime)
+	cached := middleware.CacheMiddleware(site.responseCache)
+
+	mux := http.NewServeMux()
+
+	staticHandler := handlers.NewStaticHandler(site.assets, http.FileServer(http.Dir(utils.GetTemplatePath("static/"))))
+	mux.Handle("/static/", http.StripPrefix("/static/", staticHandler))
+
+	rateLimitRules, err := newRateLimitRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rateLimitRules.Escalator = site.escalator
+	rateLimitHandler := handlers.NewRateLimitHandler(site.escalator, rateLimitRules.RequestKey)
+
+	renderer := handlers.NewPageRenderer(site.registry, tmplData)
+	renderer.Use(pagesConditional, cached)
+	ratelimitPage := handlers.Page{Path: "/ratelimit", File: "ratelimit.html", Title: "Rate Limit Exceeded", Status: http.StatusTooManyRequests, Data: rateLimitHandler.PageData}
+	err = renderer.Register(mux,
+		handlers.Page{Path: "/", File: "home.html", Title: "Home", Data: projectsHandler.HomePageData},
+		handlers.Page{Path: "/sitemap", File: "sitemap.html", Title: "Sitemap", Data: sitemapHandler.PageData},
+		ratelimitPage,
+		handlers.Page{Path: "/resume", File: "resume.html", Title: "Resume", Data: resumeHandler.PageData},
+		handlers.Page{Path: "/projects", File: "projects.html", Title: "Projects", Data: projectsHandler.PageData},
+		handlers.Page{Path: "/changelog", File: "changelog.html", Title: "Changelog", Data: changelogHandler.PageData},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mux.Handle("/sitemap.xml", pagesConditional(http.HandlerFunc(sitemapHandler.ServeXML)))
+	mux.HandleFunc("/projects.json", projectsHandler.ServeJSON)
+	mux.HandleFunc("/resume.json", resumeHandler.ServeJSON)
+	mux.HandleFunc("/resume.txt", resumeHandler.ServeText)
+	mux.HandleFunc("/resume.md", resumeHandler.ServeMarkdown)
+
+	mux.Handle("/changelog.json", changelogConditional(cached(http.HandlerFunc(changelogHandler.ServeJSON))))
+	mux.Handle("/changelog.rss", changelogConditional(cached(http.HandlerFunc(changelogHandler.ServeRSS))))
+	mux.Handle("/changelog.atom", changelogConditional(cached(http.HandlerFunc(changelogHandler.ServeAtom))))
+	mux.Handle("/changelog.feed.json", changelogConditional(cached(http.HandlerFunc(changelogHandler.ServeJSONFeed))))
+	mux.Handle("/changelog.md", changelogConditional(cached(http.HandlerFunc(changelogHandler.ServeMarkdown))))
+
+	mux.HandleFunc("/vtuberstv", handlers.VTubersTVProjectsHandler)
+
+	if cfg.Admin.Token != "" {
+		if err := site.adminRoutes(cfg, mux, tmplData); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Metrics.Enabled && cfg.Metrics.Addr == "" {
+		mux.Handle("/metrics", metrics.Handler(cfg.Metrics.Token))
+	}
+
+	healthHandler := handlers.NewHealthHandler(version, revision(), site.started,
+		handlers.HealthCheck{Name: "templates", Check: site.registry.Err},
+		handlers.HealthCheck{Name: "changelog", Check: site.changelogReady},
+		handlers.HealthCheck{Name: "static", Check: staticReady},
+	)
+	mux.HandleFunc("/health", healthHandler.ServeReady)
+	mux.HandleFunc("/health/live", healthHandler.ServeLive)
+	mux.HandleFunc("/health/ready", healthHandler.ServeReady)
+
+	// Refused requests get the page inline, under their own URL
+	rateLimitRules.Page = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renderer.Render(w, r, ratelimitPage)
+	})
+
+	handler := middleware.RateLimitMiddleware(site.limiter, rateLimitRules)(mux)
+	handler = middleware.MetricsMiddleware(handler)
+	handler = middleware.ExtraMiddleware(handler)
+	if cfg.Server.Compress {
+		handler = middleware.CompressMiddleware(cfg.Server.CompressMinBytes)(handler)
+	}
+	if cfg.Log.Access {
+		handler = middleware.AccessLogMiddleware(newAccessLogger(cfg.Log.Format))(handler)
+	}
+	return handler, nil
+}
+
+// adminRoutes mounts the rate limiter dashboard and its API behind the admin
+// token. Its pages bypass the response cache and conditional requests.
+func (site *website) adminRoutes(cfg *config.Config, mux *http.ServeMux, tmplData models.TemplateData) error {
+	adminHandler := handlers.NewRateLimitAdminHandler(site.limiter, site.escalator)
+	adminAuth := middleware.AuthMiddleware("admin", cfg.Admin.Token)
+	// Browsers send the basic auth credentials with cross-site form posts too
+	sameOrigin := http.NewCrossOriginProtection()
+
+	renderer := handlers.NewPageRenderer(site.registry, tmplData)
+	renderer.Use(adminAuth)
+	err := renderer.Register(mux,
+		handlers.Page{Path: "/admin/ratelimit", File: "admin_ratelimit.html", Title: "Rate Limiter", Data: adminHandler.PageData},
+	)
+	if err != nil {
+		return err
+	}
+
+	mux.Handle("GET /admin/ratelimit.json", adminAuth(http.HandlerFunc(adminHandler.ServeJSON)))
+	mux.Handle("POST /admin/ratelimit/reset", adminAuth(sameOrigin.Handler(http.HandlerFunc(adminHandler.ServeReset))))
+	mux.Handle("POST /admin/ratelimit/ban", adminAuth(sameOrigin.Handler(http.HandlerFunc(adminHandler.ServeBan))))
+	return nil
+}
+
+func newAccessLogger(format string) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	}
+	return slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
+// pagesModTime is the Last-Modified time of rendered pages, the newest of the
+// files they are rendered from. It only changes when those do, so it is the
+// same across restarts and replicas.
+func (site *website) pagesModTime() time.Time {
+	latest := site.dataModTime
+	for _, modTime := range []time.Time{site.registry.ModTime(), site.changelogStore.ModTime(), site.assets.ModTime()} {
+		if modTime.After(latest) {
+			latest = modTime
+		}
+	}
+	return latest
+}
+
+// filesModTime returns the newest modification time of paths, skipping those
+// that cannot be read
+func filesModTime(paths ...string) time.Time {
+	var latest time.Time
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// changelogReady fails until CHANGELOG.md has loaded and while its latest
+// version does not parse
+func (site *website) changelogReady() error {
+	status := site.changelogStore.Status()
+	if status.Error != "" {
+		return errors.New(status.Error)
+	}
+	if !status.Loaded {
+		return errors.New("changelog not loaded")
+	}
+	return nil
+}
+
+func staticReady() error {
+	info, err := os.Stat(utils.GetTemplatePath("static"))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", info.Name())
+	}
+	return nil
+}
+
+// limiterBackend is a Limiter whose limits can change on reload
+type limiterBackend interface {
+	models.Limiter
+	SetConfig(config models.RateLimiterConfig)
+	Stop()
+}
+
+// newLimiter builds the rate limiter backend selected in cfg
+func newLimiter(cfg *config.Config) (limiterBackend, error) {
+	if cfg.RateLimit.Backend == "redis" {
+		client, err := redis.NewClient(cfg.RateLimit.RedisURL, 8, time.Second)
+		if err != nil {
+			return nil, err
+		}
+		return models.NewRedisLimiter(client, cfg.RateLimit.RedisPrefix, cfg.RateLimiter()), nil
+	}
+
+	limiter := models.NewRateLimiter(cfg.RateLimiter())
+	if cfg.RateLimit.StateFile != "" {
+		restored, err := limiter.Restore(cfg.RateLimit.StateFile)
+		if err != nil {
+			// A damaged file only costs the saved budgets, it is replaced on the next save
+			log.Printf("ratelimit: restoring state: %v", err)
+		} else {
+			log.Printf("ratelimit: restored %d keys from %s", restored, cfg.RateLimit.StateFile)
+		}
+		limiter.Persist(cfg.RateLimit.StateFile, cfg.RateLimit.StateInterval)
+	}
+	return limiter, nil
+}
+
+// newRateLimitRules builds the rate limit key, policy routes and client lists
+// configured in cfg
+func newRateLimitRules(cfg *config.Config) (*middleware.RateLimitRules, error) {
+	clientIP, err := middleware.NewClientIP(cfg.RateLimit.TrustedProxies, cfg.RateLimit.IPv6Prefix)
+	if err != nil {
+		return nil, err
+	}
+	key, err := middleware.NewKeyFunc(cfg.RateLimit.Key, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	allowIPs, err := middleware.ParsePrefixes(cfg.RateLimit.AllowIPs)
+	if err != nil {
+		return nil, err
+	}
+	denyIPs, err := middleware.ParsePrefixes(cfg.RateLimit.DenyIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &middleware.RateLimitRules{
+		Key:             key,
+		ClientIP:        clientIP,
+		Routes:          cfg.RateLimitRoutes(),
+		ExemptPaths:     cfg.RateLimit.ExemptPaths,
+		AllowIPs:        allowIPs,
+		AllowUserAgents: cfg.RateLimit.AllowUserAgents,
+		DenyIPs:         denyIPs,
+		DenyUserAgents:  cfg.RateLimit.DenyUserAgents,
+	}, nil
+}
+
+// reloadableHandler lets a reload swap the routes while requests are in flight
+type reloadableHandler struct {
+	handler http.Handler
+	mutex   sync.RWMutex
+}
+
+func (rh *reloadableHandler) Set(handler http.Handler) {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+	rh.handler = handler
+}
+
+func (rh *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rh.mutex.RLock()
+	handler := rh.handler
+	rh.mutex.RUnlock()
+
+	handler.ServeHTTP(w, r)
+}
@@ -3,8 +3,15 @@ module github.com/0x800a6/www
 go 1.25.1
 
 require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/alicebob/miniredis/v2 v2.39.0
+	github.com/andybalholm/brotli v1.2.0
+	github.com/klauspost/compress v1.18.0
 	github.com/tdewolff/minify/v2 v2.24.3
 	github.com/yuin/goldmark v1.7.1
 )
 
-require github.com/tdewolff/parse/v2 v2.8.3 // indirect
+require (
+	github.com/tdewolff/parse/v2 v2.8.3 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+)
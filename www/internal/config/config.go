@@ -0,0 +1,567 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/0x800a6/www/internal/middleware"
+	"github.com/0x800a6/www/internal/models"
+)
+
+// Config holds every setting of the website server. Values are resolved from
+// the defaults, then the TOML file, then WWW_* environment variables, then
+// command line flags.
+type Config struct {
+	Path      string          `toml:"-"`
+	Server    ServerConfig    `toml:"server"`
+	Site      SiteConfig      `toml:"site"`
+	RateLimit RateLimitConfig `toml:"ratelimit"`
+	Log       LogConfig       `toml:"log"`
+	Metrics   MetricsConfig   `toml:"metrics"`
+	Admin     AdminConfig     `toml:"admin"`
+}
+
+type ServerConfig struct {
+	Addr               string        `toml:"addr"`
+	BaseURL            string        `toml:"base_url"`
+	Dev                bool          `toml:"dev"`
+	ReadHeaderTimeout  time.Duration `toml:"read_header_timeout"`
+	ReadTimeout        time.Duration `toml:"read_timeout"`
+	WriteTimeout       time.Duration `toml:"write_timeout"`
+	IdleTimeout        time.Duration `toml:"idle_timeout"`
+	ShutdownTimeout    time.Duration `toml:"shutdown_timeout"`
+	ResponseCacheBytes int           `toml:"response_cache_bytes"`
+	Compress           bool          `toml:"compress"`
+	CompressMinBytes   int           `toml:"compress_min_bytes"`
+}
+
+type SiteConfig struct {
+	Name        string `toml:"name"`
+	Description string `toml:"description"`
+	Author      string `toml:"author"`
+}
+
+// RateLimitConfig selects the limiter backend and its limits. The redis
+// backend shares limits across instances through redis_url. The top-level
+// limits form the default policy, used by routes no other policy claims.
+type RateLimitConfig struct {
+	Backend           string        `toml:"backend"`
+	RedisURL          string        `toml:"redis_url"`
+	RedisPrefix       string        `toml:"redis_prefix"`
+	Algorithm         string        `toml:"algorithm"`
+	Key               string        `toml:"key"`
+	TrustedProxies    []string      `toml:"trusted_proxies"`
+	IPv6Prefix        int           `toml:"ipv6_prefix"`
+	StateFile         string        `toml:"state_file"`
+	StateInterval     time.Duration `toml:"state_interval"`
+	RequestsPerMinute int           `toml:"requests_per_minute"`
+	BurstSize         int           `toml:"burst_size"`
+	WindowSize        time.Duration `toml:"window_size"`
+	CleanupInterval   time.Duration `toml:"cleanup_interval"`
+	ExemptPaths       []string      `toml:"exempt_paths"`
+	AllowIPs          []string      `toml:"allow_ips"`
+	AllowUserAgents   []string      `toml:"allow_user_agents"`
+	DenyIPs           []string      `toml:"deny_ips"`
+	DenyUserAgents    []string      `toml:"deny_user_agents"`
+
+	Policies   []RateLimitPolicyConfig `toml:"policies"`
+	Escalation EscalationConfig        `toml:"escalation"`
+}
+
+// RateLimitPolicyConfig limits a group of routes separately from the default
+// policy. Paths are matched in the order policies are declared, and limits
+// left unset are inherited from the default policy.
+type RateLimitPolicyConfig struct {
+	Name              string        `toml:"name"`
+	Paths             []string      `toml:"paths"`
+	Algorithm         string        `toml:"algorithm"`
+	RequestsPerMinute int           `toml:"requests_per_minute"`
+	BurstSize         int           `toml:"burst_size"`
+	WindowSize        time.Duration `toml:"window_size"`
+}
+
+// EscalationConfig bans keys that keep exceeding their limit. A key refused
+// strikes times within period is banned for penalty, doubled on every further
+// ban up to max_penalty, until the key has gone forget without one.
+type EscalationConfig struct {
+	Strikes    int           `toml:"strikes"`
+	Period     time.Duration `toml:"period"`
+	Penalty    time.Duration `toml:"penalty"`
+	MaxPenalty time.Duration `toml:"max_penalty"`
+	Forget     time.Duration `toml:"forget"`
+}
+
+type LogConfig struct {
+	Access bool   `toml:"access"`
+	Format string `toml:"format"`
+}
+
+// MetricsConfig controls the Prometheus endpoint. With an addr it is served
+// on a separate admin listener instead of the public one.
+type MetricsConfig struct {
+	Enabled bool   `toml:"enabled"`
+	Addr    string `toml:"addr"`
+	Token   string `toml:"token"`
+}
+
+// AdminConfig protects the /admin/ pages, which are only served when a token
+// is set
+type AdminConfig struct {
+	Token string `toml:"token"`
+}
+
+// Default returns the settings used for lrr.sh when nothing is overridden
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:               ":8080",
+			BaseURL:            "https://lrr.sh",
+			ReadHeaderTimeout:  5 * time.Second,
+			ReadTimeout:        15 * time.Second,
+			WriteTimeout:       30 * time.Second,
+			IdleTimeout:        2 * time.Minute,
+			ShutdownTimeout:    10 * time.Second,
+			ResponseCacheBytes: 16 << 20,
+			Compress:           true,
+			CompressMinBytes:   1024,
+		},
+		Site: SiteConfig{
+			Name:        "Lexi's Website",
+			Description: "Software & Web Developer, Cosplayer, Anime Enthusiast, and Privacy Advocate. Building things on Arch Linux with C, Rust, TypeScript, and more.",
+			Author:      "Lexi Rose Rogers",
+		},
+		RateLimit: RateLimitConfig{
+			Backend:           "memory",
+			RedisURL:          "redis://localhost:6379/0",
+			RedisPrefix:       "www:ratelimit:",
+			Algorithm:         string(models.TokenBucket),
+			Key:               "ip",
+			IPv6Prefix:        64,
+			StateInterval:     time.Minute,
+			RequestsPerMinute: 60,
+			BurstSize:         10,
+			WindowSize:        15 * time.Second,
+			CleanupInterval:   5 * time.Minute,
+			ExemptPaths:       []string{"/static/", "/health", "/health/", "/ratelimit", "/favicon.ico", "/robots.txt"},
+			Policies: []RateLimitPolicyConfig{
+				{
+					// Every request counts, so guessing the token gets banned
+					Name:              "admin",
+					Paths:             []string{"/admin/"},
+					RequestsPerMinute: 10,
+					BurstSize:         5,
+				},
+				{
+					Name:              "api",
+					Paths:             []string{"/*.json", "/changelog.*", "/resume.*", "/sitemap.xml"},
+					RequestsPerMinute: 30,
+					BurstSize:         5,
+				},
+				{
+					Name:              "redirects",
+					Paths:             []string{"/vtuberstv"},
+					RequestsPerMinute: 10,
+					BurstSize:         3,
+				},
+			},
+			Escalation: EscalationConfig{
+				Strikes:    5,
+				Period:     10 * time.Minute,
+				Penalty:    time.Minute,
+				MaxPenalty: time.Hour,
+				Forget:     24 * time.Hour,
+			},
+		},
+		Log: LogConfig{
+			Access: true,
+			Format: "logfmt",
+		},
+	}
+}
+
+// Load resolves the configuration from the command line arguments, the
+// config file they or WWW_CONFIG point to, and the environment
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("website", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv("WWW_CONFIG"), "path to a TOML config file")
+	addr := flags.String("addr", "", "listen address, e.g. :8080")
+	baseURL := flags.String("base-url", "", "public URL used in feeds and the sitemap")
+	dev := flags.Bool("dev", false, "watch templates/ and html/ and re-parse them on change")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	cfg.Path = *path
+
+	if cfg.Path != "" {
+		meta, err := toml.DecodeFile(cfg.Path, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", cfg.Path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("config %s: unknown setting %q", cfg.Path, undecoded[0].String())
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	// Only flags given explicitly override the file and environment
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "base-url":
+			cfg.Server.BaseURL = *baseURL
+		case "dev":
+			cfg.Server.Dev = *dev
+		}
+	})
+
+	cfg.Server.BaseURL = strings.TrimSuffix(cfg.Server.BaseURL, "/")
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) applyEnv() error {
+	texts := map[string]*string{
+		"WWW_ADDR":                   &cfg.Server.Addr,
+		"WWW_BASE_URL":               &cfg.Server.BaseURL,
+		"WWW_SITE_NAME":              &cfg.Site.Name,
+		"WWW_SITE_DESCRIPTION":       &cfg.Site.Description,
+		"WWW_SITE_AUTHOR":            &cfg.Site.Author,
+		"WWW_LOG_FORMAT":             &cfg.Log.Format,
+		"WWW_RATELIMIT_BACKEND":      &cfg.RateLimit.Backend,
+		"WWW_RATELIMIT_REDIS_URL":    &cfg.RateLimit.RedisURL,
+		"WWW_RATELIMIT_REDIS_PREFIX": &cfg.RateLimit.RedisPrefix,
+		"WWW_RATELIMIT_ALGORITHM":    &cfg.RateLimit.Algorithm,
+		"WWW_RATELIMIT_KEY":          &cfg.RateLimit.Key,
+		"WWW_RATELIMIT_STATE_FILE":   &cfg.RateLimit.StateFile,
+		"WWW_METRICS_ADDR":           &cfg.Metrics.Addr,
+		"WWW_METRICS_TOKEN":          &cfg.Metrics.Token,
+		"WWW_ADMIN_TOKEN":            &cfg.Admin.Token,
+	}
+	for name, field := range texts {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+
+	ints := map[string]*int{
+		"WWW_RESPONSE_CACHE_BYTES":          &cfg.Server.ResponseCacheBytes,
+		"WWW_COMPRESS_MIN_BYTES":            &cfg.Server.CompressMinBytes,
+		"WWW_RATELIMIT_REQUESTS_PER_MINUTE": &cfg.RateLimit.RequestsPerMinute,
+		"WWW_RATELIMIT_BURST_SIZE":          &cfg.RateLimit.BurstSize,
+		"WWW_RATELIMIT_IPV6_PREFIX":         &cfg.RateLimit.IPv6Prefix,
+		"WWW_RATELIMIT_ESCALATION_STRIKES":  &cfg.RateLimit.Escalation.Strikes,
+	}
+	for name, field := range ints {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*field = parsed
+		}
+	}
+
+	// Lists are comma separated
+	lists := map[string]*[]string{
+		"WWW_RATELIMIT_TRUSTED_PROXIES":   &cfg.RateLimit.TrustedProxies,
+		"WWW_RATELIMIT_EXEMPT_PATHS":      &cfg.RateLimit.ExemptPaths,
+		"WWW_RATELIMIT_ALLOW_IPS":         &cfg.RateLimit.AllowIPs,
+		"WWW_RATELIMIT_ALLOW_USER_AGENTS": &cfg.RateLimit.AllowUserAgents,
+		"WWW_RATELIMIT_DENY_IPS":          &cfg.RateLimit.DenyIPs,
+		"WWW_RATELIMIT_DENY_USER_AGENTS":  &cfg.RateLimit.DenyUserAgents,
+	}
+	for name, field := range lists {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = nil
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*field = append(*field, item)
+				}
+			}
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"WWW_READ_HEADER_TIMEOUT":              &cfg.Server.ReadHeaderTimeout,
+		"WWW_READ_TIMEOUT":                     &cfg.Server.ReadTimeout,
+		"WWW_WRITE_TIMEOUT":                    &cfg.Server.WriteTimeout,
+		"WWW_IDLE_TIMEOUT":                     &cfg.Server.IdleTimeout,
+		"WWW_SHUTDOWN_TIMEOUT":                 &cfg.Server.ShutdownTimeout,
+		"WWW_RATELIMIT_WINDOW_SIZE":            &cfg.RateLimit.WindowSize,
+		"WWW_RATELIMIT_CLEANUP_INTERVAL":       &cfg.RateLimit.CleanupInterval,
+		"WWW_RATELIMIT_STATE_INTERVAL":         &cfg.RateLimit.StateInterval,
+		"WWW_RATELIMIT_ESCALATION_PERIOD":      &cfg.RateLimit.Escalation.Period,
+		"WWW_RATELIMIT_ESCALATION_PENALTY":     &cfg.RateLimit.Escalation.Penalty,
+		"WWW_RATELIMIT_ESCALATION_MAX_PENALTY": &cfg.RateLimit.Escalation.MaxPenalty,
+		"WWW_RATELIMIT_ESCALATION_FORGET":      &cfg.RateLimit.Escalation.Forget,
+	}
+	for name, field := range durations {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*field = parsed
+		}
+	}
+
+	bools := map[string]*bool{
+		"WWW_DEV":        &cfg.Server.Dev,
+		"WWW_COMPRESS":   &cfg.Server.Compress,
+		"WWW_LOG_ACCESS": &cfg.Log.Access,
+		"WWW_METRICS":    &cfg.Metrics.Enabled,
+	}
+	for name, field := range bools {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*field = parsed
+		}
+	}
+
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if cfg.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+
+	if u, err := url.Parse(cfg.Server.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("server.base_url %q must be an absolute http(s) URL", cfg.Server.BaseURL))
+	}
+
+	timeouts := []struct {
+		name    string
+		timeout time.Duration
+	}{
+		{"server.read_header_timeout", cfg.Server.ReadHeaderTimeout},
+		{"server.read_timeout", cfg.Server.ReadTimeout},
+		{"server.write_timeout", cfg.Server.WriteTimeout},
+		{"server.idle_timeout", cfg.Server.IdleTimeout},
+		{"server.shutdown_timeout", cfg.Server.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.timeout < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", t.name))
+		}
+	}
+
+	if cfg.Server.ResponseCacheBytes < 0 {
+		errs = append(errs, errors.New("server.response_cache_bytes must not be negative"))
+	}
+	if cfg.Server.CompressMinBytes < 0 {
+		errs = append(errs, errors.New("server.compress_min_bytes must not be negative"))
+	}
+
+	if cfg.Site.Name == "" {
+		errs = append(errs, errors.New("site.name is required"))
+	}
+
+	switch cfg.RateLimit.Backend {
+	case "memory":
+	case "redis":
+		if u, err := url.Parse(cfg.RateLimit.RedisURL); err != nil || u.Scheme != "redis" {
+			errs = append(errs, fmt.Errorf("ratelimit.redis_url %q must be a redis:// URL", cfg.RateLimit.RedisURL))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("ratelimit.backend %q must be memory or redis", cfg.RateLimit.Backend))
+	}
+	if _, err := models.ParseAlgorithm(cfg.RateLimit.Algorithm); err != nil {
+		errs = append(errs, fmt.Errorf("ratelimit.algorithm: %w", err))
+	}
+	for _, source := range strings.Split(cfg.RateLimit.Key, "+") {
+		if source != "ip" && source != "cookie" {
+			errs = append(errs, fmt.Errorf("ratelimit.key %q must be ip, cookie or both joined by +", cfg.RateLimit.Key))
+			break
+		}
+	}
+	addresses := []struct {
+		name   string
+		values []string
+	}{
+		{"ratelimit.trusted_proxies", cfg.RateLimit.TrustedProxies},
+		{"ratelimit.allow_ips", cfg.RateLimit.AllowIPs},
+		{"ratelimit.deny_ips", cfg.RateLimit.DenyIPs},
+	}
+	for _, a := range addresses {
+		if _, err := middleware.ParsePrefixes(a.values); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", a.name, err))
+		}
+	}
+	for _, pattern := range cfg.RateLimit.ExemptPaths {
+		if err := validatePathPattern(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("ratelimit.exempt_paths: %w", err))
+		}
+	}
+	if cfg.RateLimit.IPv6Prefix < 1 || cfg.RateLimit.IPv6Prefix > 128 {
+		errs = append(errs, errors.New("ratelimit.ipv6_prefix must be between 1 and 128"))
+	}
+	if cfg.RateLimit.RequestsPerMinute <= 0 {
+		errs = append(errs, errors.New("ratelimit.requests_per_minute must be positive"))
+	}
+	if cfg.RateLimit.BurstSize <= 0 {
+		errs = append(errs, errors.New("ratelimit.burst_size must be positive"))
+	}
+	if cfg.RateLimit.WindowSize <= 0 {
+		errs = append(errs, errors.New("ratelimit.window_size must be positive"))
+	}
+	if cfg.RateLimit.CleanupInterval <= 0 {
+		errs = append(errs, errors.New("ratelimit.cleanup_interval must be positive"))
+	}
+	if cfg.RateLimit.StateFile != "" && cfg.RateLimit.StateInterval <= 0 {
+		errs = append(errs, errors.New("ratelimit.state_interval must be positive"))
+	}
+
+	if escalation := cfg.RateLimit.Escalation; escalation.Strikes < 0 {
+		errs = append(errs, errors.New("ratelimit.escalation.strikes must not be negative"))
+	} else if escalation.Strikes > 0 {
+		if escalation.Period <= 0 || escalation.Penalty <= 0 || escalation.Forget <= 0 {
+			errs = append(errs, errors.New("ratelimit.escalation period, penalty and forget must be positive"))
+		}
+		if escalation.MaxPenalty < escalation.Penalty {
+			errs = append(errs, errors.New("ratelimit.escalation.max_penalty must not be shorter than penalty"))
+		}
+	}
+
+	names := map[string]bool{models.DefaultPolicy: true}
+	for i, policy := range cfg.RateLimit.Policies {
+		name := fmt.Sprintf("ratelimit.policies[%d]", i)
+		if !policyName.MatchString(policy.Name) {
+			errs = append(errs, fmt.Errorf("%s: name %q must be lowercase letters, digits, - and _", name, policy.Name))
+		} else if names[policy.Name] {
+			errs = append(errs, fmt.Errorf("%s: name %q is already used", name, policy.Name))
+		}
+		names[policy.Name] = true
+
+		if len(policy.Paths) == 0 {
+			errs = append(errs, fmt.Errorf("%s: paths is required", name))
+		}
+		for _, pattern := range policy.Paths {
+			if err := validatePathPattern(pattern); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+		if policy.Algorithm != "" {
+			if _, err := models.ParseAlgorithm(policy.Algorithm); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+		if policy.RequestsPerMinute < 0 || policy.BurstSize < 0 || policy.WindowSize < 0 {
+			errs = append(errs, fmt.Errorf("%s: limits must not be negative", name))
+		}
+	}
+
+	if cfg.Metrics.Addr != "" && cfg.Metrics.Addr == cfg.Server.Addr {
+		errs = append(errs, errors.New("metrics.addr must differ from server.addr"))
+	}
+	// On the public listener anyone could read the traffic figures
+	if cfg.Metrics.Enabled && cfg.Metrics.Addr == "" && cfg.Metrics.Token == "" {
+		errs = append(errs, errors.New("metrics.token is required unless metrics.addr serves them on a separate listener"))
+	}
+
+	if cfg.Log.Format != "json" && cfg.Log.Format != "logfmt" {
+		errs = append(errs, fmt.Errorf("log.format %q must be json or logfmt", cfg.Log.Format))
+	}
+
+	return errors.Join(errs...)
+}
+
+var policyName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+func validatePathPattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("path %q must start with /", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("path %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// RateLimiter returns the rate limiter settings as the models package expects them
+func (cfg *Config) RateLimiter() models.RateLimiterConfig {
+	defaults := models.RateLimitPolicy{
+		Algorithm:         models.Algorithm(cfg.RateLimit.Algorithm),
+		RequestsPerMinute: cfg.RateLimit.RequestsPerMinute,
+		BurstSize:         cfg.RateLimit.BurstSize,
+		WindowSize:        cfg.RateLimit.WindowSize,
+	}
+
+	config := models.RateLimiterConfig{
+		Policies:        map[string]models.RateLimitPolicy{models.DefaultPolicy: defaults},
+		CleanupInterval: cfg.RateLimit.CleanupInterval,
+	}
+	for _, p := range cfg.RateLimit.Policies {
+		policy := defaults
+		if p.Algorithm != "" {
+			policy.Algorithm = models.Algorithm(p.Algorithm)
+		}
+		if p.RequestsPerMinute > 0 {
+			policy.RequestsPerMinute = p.RequestsPerMinute
+		}
+		if p.BurstSize > 0 {
+			policy.BurstSize = p.BurstSize
+		}
+		if p.WindowSize > 0 {
+			policy.WindowSize = p.WindowSize
+		}
+		config.Policies[p.Name] = policy
+	}
+	return config
+}
+
+// Escalation returns the escalation thresholds in the form the models use
+func (cfg *Config) Escalation() models.EscalationConfig {
+	return models.EscalationConfig{
+		Strikes:    cfg.RateLimit.Escalation.Strikes,
+		Period:     cfg.RateLimit.Escalation.Period,
+		Penalty:    cfg.RateLimit.Escalation.Penalty,
+		MaxPenalty: cfg.RateLimit.Escalation.MaxPenalty,
+		Forget:     cfg.RateLimit.Escalation.Forget,
+	}
+}
+
+// RateLimitRoutes returns the policies in the order their paths are matched
+func (cfg *Config) RateLimitRoutes() []middleware.RateLimitRoute {
+	routes := make([]middleware.RateLimitRoute, len(cfg.RateLimit.Policies))
+	for i, policy := range cfg.RateLimit.Policies {
+		routes[i] = middleware.RateLimitRoute{Policy: policy.Name, Paths: policy.Paths}
+	}
+	return routes
+}
+
+// TemplateData returns the site data shared by every page
+func (cfg *Config) TemplateData() models.TemplateData {
+	var data models.TemplateData
+	data.Site.Name = cfg.Site.Name
+	data.Site.Description = cfg.Site.Description
+	data.Site.Author = cfg.Site.Author
+	data.Site.BaseURL = cfg.Server.BaseURL
+	data.Site.Year = time.Now().Year()
+	return data
+}
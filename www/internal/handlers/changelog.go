@@ -1,261 +1,169 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
-	"html/template"
+	"encoding/xml"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/0x800a6/www/internal/middleware"
 	"github.com/0x800a6/www/internal/models"
-	"github.com/0x800a6/www/internal/utils"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
 )
 
-// ChangelogHandler handles changelog page requests
-func ChangelogHandler(w http.ResponseWriter, r *http.Request, tmplData models.TemplateData) {
-	// Load changelog markdown file
-	changelogPath := filepath.Join("CHANGELOG.md")
-	if _, err := os.Stat(changelogPath); err != nil {
-		changelogPath = filepath.Join("..", "CHANGELOG.md")
-	}
-	
-	content, err := os.ReadFile(changelogPath)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Changelog not found at %s: %v", changelogPath, err), http.StatusNotFound)
-		return
-	}
-	
-	// Parse changelog
-	changelogData, err := models.ParseChangelog(string(content))
-	if err != nil {
-		http.Error(w, "Error parsing changelog", http.StatusInternalServerError)
-		return
-	}
-	
-	// Apply filters from query parameters
-	filter := models.ChangelogFilter{
-		ShowUnreleased: true,
-	}
-	
-	if version := r.URL.Query().Get("version"); version != "" {
-		filter.Version = version
-	}
-	if changeType := r.URL.Query().Get("type"); changeType != "" {
-		filter.ChangeType = changeType
-	}
-	if search := r.URL.Query().Get("search"); search != "" {
-		filter.Search = search
-	}
-	if showUnreleased := r.URL.Query().Get("unreleased"); showUnreleased != "" {
-		filter.ShowUnreleased = showUnreleased == "true"
-	}
-	if dateFrom := r.URL.Query().Get("date_from"); dateFrom != "" {
-		if date, err := time.Parse("2006-01-02", dateFrom); err == nil {
-			filter.DateFrom = date
-		}
-	}
-	if dateTo := r.URL.Query().Get("date_to"); dateTo != "" {
-		if date, err := time.Parse("2006-01-02", dateTo); err == nil {
-			filter.DateTo = date
-		}
-	}
-	
-	// Apply filters
-	filteredData := changelogData.FilterChangelog(filter)
-	
-	// Get statistics
-	stats := changelogData.GetStats()
-	versions := changelogData.GetVersions()
-	changeTypes := changelogData.GetChangeTypes()
-	
-	// Prepare template data
-	data := tmplData
-	data.Page = models.PageData{
-		Title:   "Changelog",
-		Content: "changelog",
-		Data: struct {
-			Changelog   *models.ChangelogData
-			Stats       models.ChangelogStats
-			Versions    []string
-			ChangeTypes []string
-			Filter      models.ChangelogFilter
-		}{
-			Changelog:   filteredData,
-			Stats:       stats,
-			Versions:    versions,
-			ChangeTypes: changeTypes,
-			Filter:      filter,
-		},
-	}
-	
-	// Parse templates
-	allTmpl, err := template.ParseGlob(utils.GetTemplatePath("templates/*.html"))
-	if err != nil {
-		http.Error(w, "Template parsing error", http.StatusInternalServerError)
-		return
-	}
+// ChangelogHandler serves the changelog page, API, feed and markdown from a
+// ChangelogStore
+type ChangelogHandler struct {
+	Store    *models.ChangelogStore
+	BaseURL  string
+	tmplData models.TemplateData
+}
 
-	
-	allTmpl, err = allTmpl.ParseFiles(utils.GetTemplatePath("html/changelog.html"))
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Template parsing error: %v", err), http.StatusInternalServerError)
-		return
-	}
-	
-	// Use minify writer
-	minifyWriter := middleware.NewMinifyResponseWriter(w)
-	
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err = allTmpl.ExecuteTemplate(minifyWriter, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	
-	err = minifyWriter.Flush()
-	if err != nil {
-		// Log error but don't fail the request
+// maxFeedEntries limits feeds to the most recent versions
+const maxFeedEntries = 20
+
+func NewChangelogHandler(store *models.ChangelogStore, tmplData models.TemplateData, baseURL string) *ChangelogHandler {
+	return &ChangelogHandler{
+		Store:    store,
+		BaseURL:  baseURL,
+		tmplData: tmplData,
 	}
 }
 
-// ChangelogAPIHandler handles API requests for changelog data
-func ChangelogAPIHandler(w http.ResponseWriter, r *http.Request, tmplData models.TemplateData) {
-	// Load changelog markdown file
-	changelogPath := filepath.Join("CHANGELOG.md")
-	if _, err := os.Stat(changelogPath); err != nil {
-		changelogPath = filepath.Join("..", "CHANGELOG.md")
-	}
-	
-	content, err := os.ReadFile(changelogPath)
-	if err != nil {
-		http.Error(w, "Changelog not found", http.StatusNotFound)
-		return
+// feed builds the format-independent feed shared by RSS, Atom and JSON Feed
+func (ch *ChangelogHandler) feed() *models.Feed {
+	return models.NewChangelogFeed(ch.Store.Data(), models.FeedInfo{
+		Title:       ch.tmplData.Site.Name + " - Changelog",
+		Description: ch.tmplData.Site.Description,
+		Author:      ch.tmplData.Site.Author,
+		Link:        ch.BaseURL + "/changelog",
+	}, maxFeedEntries)
+}
+
+// loaded reports whether the store has ever loaded the changelog, writing a
+// 404 otherwise. Reload failures after that keep serving the last good data.
+func (ch *ChangelogHandler) loaded(w http.ResponseWriter) bool {
+	if ch.Store.Status().Loaded {
+		return true
+	}
+	http.Error(w, "Changelog not found", http.StatusNotFound)
+	return false
+}
+
+// PageData builds the changelog page data from query parameters
+func (ch *ChangelogHandler) PageData(r *http.Request) (interface{}, error) {
+	if !ch.Store.Status().Loaded {
+		return nil, &PageError{Status: http.StatusNotFound, Message: "The changelog could not be found."}
 	}
-	
-	// Parse changelog
-	changelogData, err := models.ParseChangelog(string(content))
-	if err != nil {
-		http.Error(w, "Error parsing changelog", http.StatusInternalServerError)
+
+	changelogData := ch.Store.Data()
+	filter := parseChangelogFilter(r)
+
+	return struct {
+		Changelog   *models.ChangelogData
+		Stats       models.ChangelogStats
+		Versions    []string
+		ChangeTypes []string
+		Filter      models.ChangelogFilter
+	}{
+		Changelog:   changelogData.FilterChangelog(filter),
+		Stats:       changelogData.GetStats(),
+		Versions:    changelogData.GetVersions(),
+		ChangeTypes: changelogData.GetChangeTypes(),
+		Filter:      filter,
+	}, nil
+}
+
+// ServeJSON handles API requests for changelog data
+func (ch *ChangelogHandler) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	if !ch.loaded(w) {
 		return
 	}
-	
-	// Apply filters from query parameters
-	filter := models.ChangelogFilter{
-		ShowUnreleased: true,
-	}
-	
-	if version := r.URL.Query().Get("version"); version != "" {
-		filter.Version = version
-	}
-	if changeType := r.URL.Query().Get("type"); changeType != "" {
-		filter.ChangeType = changeType
-	}
-	if search := r.URL.Query().Get("search"); search != "" {
-		filter.Search = search
-	}
-	if showUnreleased := r.URL.Query().Get("unreleased"); showUnreleased != "" {
-		filter.ShowUnreleased = showUnreleased == "true"
-	}
-	if dateFrom := r.URL.Query().Get("date_from"); dateFrom != "" {
-		if date, err := time.Parse("2006-01-02", dateFrom); err == nil {
-			filter.DateFrom = date
-		}
-	}
-	if dateTo := r.URL.Query().Get("date_to"); dateTo != "" {
-		if date, err := time.Parse("2006-01-02", dateTo); err == nil {
-			filter.DateTo = date
-		}
-	}
-	
-	// Apply filters
-	filteredData := changelogData.FilterChangelog(filter)
-	
-	// Get statistics
-	stats := changelogData.GetStats()
-	versions := changelogData.GetVersions()
-	changeTypes := changelogData.GetChangeTypes()
-	
-	// Prepare response data
+
+	changelogData := ch.Store.Data()
+	filter := parseChangelogFilter(r)
+
 	responseData := struct {
-		Changelog   *models.ChangelogData `json:"changelog"`
-		Stats       models.ChangelogStats `json:"stats"`
-		Versions    []string              `json:"versions"`
-		ChangeTypes []string              `json:"change_types"`
+		Changelog   *models.ChangelogData  `json:"changelog"`
+		Stats       models.ChangelogStats  `json:"stats"`
+		Versions    []string               `json:"versions"`
+		ChangeTypes []string               `json:"change_types"`
 		Filter      models.ChangelogFilter `json:"filter"`
 	}{
-		Changelog:   filteredData,
-		Stats:       stats,
-		Versions:    versions,
-		ChangeTypes: changeTypes,
+		Changelog:   changelogData.FilterChangelog(filter),
+		Stats:       changelogData.GetStats(),
+		Versions:    changelogData.GetVersions(),
+		ChangeTypes: changelogData.GetChangeTypes(),
 		Filter:      filter,
 	}
-	
-	// Set JSON content type
+
 	w.Header().Set("Content-Type", "application/json")
-	
-	// Encode and send response
+
 	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
-	err = encoder.Encode(responseData)
-	if err != nil {
+	if err := encoder.Encode(responseData); err != nil {
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 }
 
-// ChangelogRSSHandler handles RSS feed requests for changelog
-func ChangelogRSSHandler(w http.ResponseWriter, r *http.Request, tmplData models.TemplateData) {
-	// Load changelog markdown file
-	changelogPath := filepath.Join("CHANGELOG.md")
-	if _, err := os.Stat(changelogPath); err != nil {
-		changelogPath = filepath.Join("..", "CHANGELOG.md")
-	}
-	
-	content, err := os.ReadFile(changelogPath)
-	if err != nil {
-		http.Error(w, "Changelog not found", http.StatusNotFound)
+// ServeRSS handles RSS feed requests for changelog
+func (ch *ChangelogHandler) ServeRSS(w http.ResponseWriter, r *http.Request) {
+	if !ch.loaded(w) {
 		return
 	}
-	
-	// Parse changelog
-	changelogData, err := models.ParseChangelog(string(content))
-	if err != nil {
-		http.Error(w, "Error parsing changelog", http.StatusInternalServerError)
+
+	rss := ch.feed().RSS(ch.BaseURL + "/changelog.rss")
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	writeXML(w, rss)
+}
+
+// ServeAtom handles Atom feed requests for changelog
+func (ch *ChangelogHandler) ServeAtom(w http.ResponseWriter, r *http.Request) {
+	if !ch.loaded(w) {
 		return
 	}
-	
-	// Generate RSS feed
-	rssContent := generateRSSFeed(changelogData, tmplData)
-	
-	w.Header().Set("Content-Type", "application/rss+xml")
-	w.Write([]byte(rssContent))
+
+	atom := ch.feed().Atom(ch.BaseURL + "/changelog.atom")
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	writeXML(w, atom)
 }
 
-// ChangelogMarkdownHandler handles raw markdown requests
-func ChangelogMarkdownHandler(w http.ResponseWriter, r *http.Request, tmplData models.TemplateData) {
-	// Load changelog markdown file
-	changelogPath := filepath.Join("CHANGELOG.md")
-	if _, err := os.Stat(changelogPath); err != nil {
-		changelogPath = filepath.Join("..", "CHANGELOG.md")
+// ServeJSONFeed handles JSON Feed requests for changelog
+func (ch *ChangelogHandler) ServeJSONFeed(w http.ResponseWriter, r *http.Request) {
+	if !ch.loaded(w) {
+		return
+	}
+
+	jsonFeed := ch.feed().JSONFeed(ch.BaseURL + "/changelog.feed.json")
+
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(jsonFeed); err != nil {
+		http.Error(w, "Error generating feed", http.StatusInternalServerError)
+		return
 	}
-	
-	content, err := os.ReadFile(changelogPath)
-	if err != nil {
-		http.Error(w, "Changelog not found", http.StatusNotFound)
+}
+
+// ServeMarkdown handles raw markdown requests
+func (ch *ChangelogHandler) ServeMarkdown(w http.ResponseWriter, r *http.Request) {
+	if !ch.loaded(w) {
 		return
 	}
-	
+
+	content := ch.Store.Content()
+
 	// Check if HTML conversion is requested
 	if r.URL.Query().Get("format") == "html" {
-		// Convert markdown to HTML
 		md := goldmark.New(
 			goldmark.WithExtensions(extension.GFM),
 			goldmark.WithParserOptions(
@@ -266,71 +174,66 @@ func ChangelogMarkdownHandler(w http.ResponseWriter, r *http.Request, tmplData m
 				html.WithXHTML(),
 			),
 		)
-		
+
 		var buf strings.Builder
 		if err := md.Convert(content, &buf); err != nil {
 			http.Error(w, "Error converting markdown", http.StatusInternalServerError)
 			return
 		}
-		
+
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Write([]byte(buf.String()))
 	} else {
-		// Return raw markdown
 		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
 		w.Write(content)
 	}
 }
 
-// generateRSSFeed generates RSS feed content for changelog
-func generateRSSFeed(changelogData *models.ChangelogData, tmplData models.TemplateData) string {
-	var rss strings.Builder
-	
-	rss.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
-	rss.WriteString(`<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">`)
-	rss.WriteString(`<channel>`)
-	rss.WriteString(`<title>` + tmplData.Site.Name + ` - Changelog</title>`)
-	rss.WriteString(`<description>` + tmplData.Site.Description + `</description>`)
-	rss.WriteString(`<link>https://lrr.sh/changelog</link>`)
-	rss.WriteString(`<atom:link href="https://lrr.sh/changelog.rss" rel="self" type="application/rss+xml"/>`)
-	rss.WriteString(`<language>en-us</language>`)
-	rss.WriteString(`<lastBuildDate>` + time.Now().Format(time.RFC1123Z) + `</lastBuildDate>`)
-	
-	// Add entries (limit to 20 most recent)
-	maxEntries := 20
-	if len(changelogData.Entries) < maxEntries {
-		maxEntries = len(changelogData.Entries)
+// parseChangelogFilter builds a ChangelogFilter from query parameters
+func parseChangelogFilter(r *http.Request) models.ChangelogFilter {
+	filter := models.ChangelogFilter{
+		ShowUnreleased: true,
+	}
+
+	query := r.URL.Query()
+	if version := query.Get("version"); version != "" {
+		filter.Version = version
+	}
+	if changeType := query.Get("type"); changeType != "" {
+		filter.ChangeType = changeType
+	}
+	if search := query.Get("search"); search != "" {
+		filter.Search = search
 	}
-	
-	for i := 0; i < maxEntries; i++ {
-		entry := changelogData.Entries[i]
-		
-		rss.WriteString(`<item>`)
-		rss.WriteString(`<title>Version ` + entry.Version + `</title>`)
-		rss.WriteString(`<link>https://lrr.sh/changelog#` + entry.Version + `</link>`)
-		rss.WriteString(`<guid>https://lrr.sh/changelog#` + entry.Version + `</guid>`)
-		
-		if !entry.Date.IsZero() {
-			rss.WriteString(`<pubDate>` + entry.Date.Format(time.RFC1123Z) + `</pubDate>`)
+	if showUnreleased := query.Get("unreleased"); showUnreleased != "" {
+		filter.ShowUnreleased = showUnreleased == "true"
+	}
+	if dateFrom := query.Get("date_from"); dateFrom != "" {
+		if date, err := time.Parse("2006-01-02", dateFrom); err == nil {
+			filter.DateFrom = date
 		}
-		
-		// Generate description from changes
-		var description strings.Builder
-		for _, change := range entry.Changes {
-			description.WriteString(`<h3>` + change.Type + `</h3>`)
-			description.WriteString(`<ul>`)
-			for _, item := range change.Items {
-				description.WriteString(`<li>` + item + `</li>`)
-			}
-			description.WriteString(`</ul>`)
+	}
+	if dateTo := query.Get("date_to"); dateTo != "" {
+		if date, err := time.Parse("2006-01-02", dateTo); err == nil {
+			filter.DateTo = date
 		}
-		
-		rss.WriteString(`<description><![CDATA[` + description.String() + `]]></description>`)
-		rss.WriteString(`</item>`)
 	}
-	
-	rss.WriteString(`</channel>`)
-	rss.WriteString(`</rss>`)
-	
-	return rss.String()
+
+	return filter
+}
+
+// writeXML encodes a feed document into a buffer first so an encoding
+// error still produces a clean 500 instead of a truncated feed
+func writeXML(w http.ResponseWriter, document interface{}) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		http.Error(w, "Error generating feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(buf.Bytes())
 }
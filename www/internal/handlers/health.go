@@ -3,11 +3,100 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"runtime"
+	"time"
 )
 
-func HealthHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	ToJSON(w, map[string]string{"status": "healthy", "service": "go-website"})
+// HealthCheck is a named readiness dependency, Check returns nil when healthy
+type HealthCheck struct {
+	Name  string
+	Check func() error
+}
+
+type HealthCheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthHandler serves liveness and readiness probes along with build info
+type HealthHandler struct {
+	Version string
+	Commit  string
+	started time.Time
+	checks  []HealthCheck
+}
+
+func NewHealthHandler(version, commit string, started time.Time, checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{
+		Version: version,
+		Commit:  commit,
+		started: started,
+		checks:  checks,
+	}
+}
+
+type healthResponse struct {
+	Status        string              `json:"status"`
+	Service       string              `json:"service"`
+	Version       string              `json:"version"`
+	Commit        string              `json:"commit,omitempty"`
+	GoVersion     string              `json:"go_version"`
+	Started       time.Time           `json:"started"`
+	Uptime        string              `json:"uptime"`
+	UptimeSeconds int64               `json:"uptime_seconds"`
+	Checks        []HealthCheckResult `json:"checks,omitempty"`
+}
+
+func (hh *HealthHandler) response(status string) healthResponse {
+	uptime := time.Since(hh.started)
+
+	return healthResponse{
+		Status:        status,
+		Service:       "go-website",
+		Version:       hh.Version,
+		Commit:        hh.Commit,
+		GoVersion:     runtime.Version(),
+		Started:       hh.started,
+		Uptime:        uptime.Truncate(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+	}
+}
+
+// ServeLive reports that the process is up and serving, without checking
+// any dependency
+func (hh *HealthHandler) ServeLive(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, hh.response("alive"))
+}
+
+// ServeReady runs every check and answers 503 with the failing ones if the
+// site cannot serve its pages
+func (hh *HealthHandler) ServeReady(w http.ResponseWriter, r *http.Request) {
+	response := hh.response("ready")
+	status := http.StatusOK
+
+	for _, check := range hh.checks {
+		result := HealthCheckResult{Name: check.Name, Status: "ok"}
+		if err := check.Check(); err != nil {
+			result.Status = "fail"
+			result.Error = err.Error()
+			response.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+		response.Checks = append(response.Checks, result)
+	}
+
+	writeHealth(w, status, response)
+}
+
+func writeHealth(w http.ResponseWriter, status int, response healthResponse) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(response)
 }
 
 func ToJSON(w http.ResponseWriter, data interface{}) error {
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/0x800a6/www/internal/metrics"
+	"github.com/0x800a6/www/internal/middleware"
+	"github.com/0x800a6/www/internal/models"
+	"github.com/0x800a6/www/internal/templates"
+)
+
+// DataFunc builds the page-specific data exposed to templates as .Page.Data
+type DataFunc func(r *http.Request) (interface{}, error)
+
+// Page declares an HTML route rendered from a file in html/
+type Page struct {
+	Path   string
+	File   string
+	Title  string
+	Data   DataFunc
+	Status int
+}
+
+// PageError lets a DataFunc choose the status and message of the error page
+// shown instead of the requested page
+type PageError struct {
+	Status  int
+	Message string
+}
+
+func (pe *PageError) Error() string {
+	return pe.Message
+}
+
+type ErrorPageData struct {
+	Status  int
+	Message string
+}
+
+const errorPage = "error.html"
+
+// PageRenderer renders declared pages through the base layout and minifier
+type PageRenderer struct {
+	registry   *templates.Registry
+	tmplData   models.TemplateData
+	middleware []func(http.Handler) http.Handler
+}
+
+func NewPageRenderer(registry *templates.Registry, tmplData models.TemplateData) *PageRenderer {
+	return &PageRenderer{
+		registry: registry,
+		tmplData: tmplData,
+	}
+}
+
+// Use wraps every page registered afterwards in the given middleware, the
+// first one outermost
+func (pr *PageRenderer) Use(middleware ...func(http.Handler) http.Handler) {
+	pr.middleware = append(pr.middleware, middleware...)
+}
+
+// Register parses the templates of every page up front and mounts them on mux
+func (pr *PageRenderer) Register(mux *http.ServeMux, pages ...Page) error {
+	files := []string{errorPage}
+	for _, page := range pages {
+		files = append(files, page.File)
+	}
+	if err := pr.registry.Register(files...); err != nil {
+		return err
+	}
+
+	for _, page := range pages {
+		var handler http.Handler = pr.Handler(page)
+		for i := len(pr.middleware) - 1; i >= 0; i-- {
+			handler = pr.middleware[i](handler)
+		}
+		mux.Handle(page.Path, handler)
+	}
+	return nil
+}
+
+func (pr *PageRenderer) Handler(page Page) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// "/" matches every unregistered path on a ServeMux
+		if r.URL.Path != page.Path {
+			pr.Error(w, r, http.StatusNotFound, "The page you are looking for does not exist.")
+			return
+		}
+
+		pr.Render(w, r, page)
+	}
+}
+
+// Render builds the page data and writes the page, falling back to the error
+// page if the data provider or template fails
+func (pr *PageRenderer) Render(w http.ResponseWriter, r *http.Request, page Page) {
+	var pageData interface{}
+	if page.Data != nil {
+		var err error
+		pageData, err = page.Data(r)
+		if err != nil {
+			var pageErr *PageError
+			if errors.As(err, &pageErr) {
+				pr.Error(w, r, pageErr.Status, pageErr.Message)
+				return
+			}
+
+			log.Printf("page %s: %v", page.Path, err)
+			pr.Error(w, r, http.StatusInternalServerError, "Something went wrong while building this page.")
+			return
+		}
+	}
+
+	status := page.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if err := pr.render(w, r, page.File, page.Title, status, pageData); err != nil {
+		metrics.TemplateRenderErrors.Inc(page.File)
+		log.Printf("page %s: %v", page.Path, err)
+		pr.Error(w, r, http.StatusInternalServerError, "Something went wrong while rendering this page.")
+	}
+}
+
+// Error renders the shared error page with the given status
+func (pr *PageRenderer) Error(w http.ResponseWriter, r *http.Request, status int, message string) {
+	data := ErrorPageData{
+		Status:  status,
+		Message: message,
+	}
+
+	if err := pr.render(w, r, errorPage, http.StatusText(status), status, data); err != nil {
+		metrics.TemplateRenderErrors.Inc(errorPage)
+		log.Printf("error page: %v", err)
+		http.Error(w, message, status)
+	}
+}
+
+func (pr *PageRenderer) render(w http.ResponseWriter, r *http.Request, file, title string, status int, pageData interface{}) error {
+	allTmpl, err := pr.registry.Get(file)
+	if err != nil {
+		return err
+	}
+
+	data := pr.tmplData
+	data.Page = models.PageData{
+		Title:   title,
+		Content: strings.TrimSuffix(file, ".html"),
+		Path:    r.URL.Path,
+		Data:    pageData,
+	}
+
+	// The minify writer buffers everything, so nothing reaches the client
+	// until the template has executed successfully
+	minifyWriter := middleware.NewMinifyResponseWriter(w)
+	if err := allTmpl.ExecuteTemplate(minifyWriter, "base.html", data); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	if err := minifyWriter.Flush(); err != nil {
+		log.Printf("page %s: writing response: %v", file, err)
+	}
+	return nil
+}
@@ -1,43 +1,123 @@
 package handlers
 
 import (
-	"html/template"
+	"encoding/json"
 	"net/http"
+	"strings"
 
-	"github.com/0x800a6/www/internal/middleware"
 	"github.com/0x800a6/www/internal/models"
-	"github.com/0x800a6/www/internal/utils"
 )
 
-func ProjectsHandler(w http.ResponseWriter, r *http.Request, tmplData models.TemplateData) {
-	data := tmplData
-	data.Page = models.PageData{
-		Title:   "Projects",
-		Content: "projects",
+// ProjectsHandler serves the project catalog to the pages that list projects
+type ProjectsHandler struct {
+	Catalog *models.ProjectCatalog
+}
+
+func NewProjectsHandler(catalog *models.ProjectCatalog) *ProjectsHandler {
+	return &ProjectsHandler{
+		Catalog: catalog,
 	}
+}
 
-	allTmpl, err := template.ParseGlob(utils.GetTemplatePath("templates/*.html"))
-	if err != nil {
-		http.Error(w, "Template parsing error", http.StatusInternalServerError)
-		return
+// projectSectionView is a category section where projects not matching the
+// current filter are rendered hidden, so client-side filtering can reveal
+// them again without a round trip
+type projectSectionView struct {
+	Category models.ProjectCategory
+	Projects []projectView
+	Visible  bool
+}
+
+type projectView struct {
+	models.Project
+	Visible bool
+}
+
+// PageData groups the catalog into sections for the projects page, marking
+// which projects match the query parameters
+func (ph *ProjectsHandler) PageData(r *http.Request) (interface{}, error) {
+	filter := parseProjectFilter(r)
+	filtered := ph.Catalog.FilterProjects(filter)
+
+	matches := make(map[string]bool, len(filtered.Projects))
+	for _, project := range filtered.Projects {
+		matches[project.Name] = true
 	}
 
-	allTmpl, err = allTmpl.ParseFiles(utils.GetTemplatePath("html/projects.html"))
-	if err != nil {
-		http.Error(w, "Page not found", http.StatusNotFound)
-		return
+	sections := []projectSectionView{}
+	for _, section := range ph.Catalog.GetSections() {
+		view := projectSectionView{Category: section.Category}
+		for _, project := range section.Projects {
+			visible := matches[project.Name]
+			view.Projects = append(view.Projects, projectView{Project: project, Visible: visible})
+			view.Visible = view.Visible || visible
+		}
+		sections = append(sections, view)
 	}
 
-	minifyWriter := middleware.NewMinifyResponseWriter(w)
+	return struct {
+		Sections   []projectSectionView
+		Statuses   []models.ProjectFilterOption
+		Languages  []models.ProjectFilterOption
+		Categories []models.ProjectCategory
+		Filter     models.ProjectFilter
+		Shown      int
+		Total      int
+	}{
+		Sections:   sections,
+		Statuses:   ph.Catalog.GetStatuses(),
+		Languages:  ph.Catalog.GetLanguages(),
+		Categories: ph.Catalog.Categories,
+		Filter:     filter,
+		Shown:      len(filtered.Projects),
+		Total:      len(ph.Catalog.Projects),
+	}, nil
+}
+
+// ServeJSON returns the filtered project list
+func (ph *ProjectsHandler) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	filter := parseProjectFilter(r)
+	filtered := ph.Catalog.FilterProjects(filter)
+
+	responseData := struct {
+		Projects   []models.Project         `json:"projects"`
+		Categories []models.ProjectCategory `json:"categories"`
+		Total      int                      `json:"total"`
+		Filter     models.ProjectFilter     `json:"filter"`
+	}{
+		Projects:   filtered.Projects,
+		Categories: ph.Catalog.Categories,
+		Total:      len(filtered.Projects),
+		Filter:     filter,
+	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err = allTmpl.ExecuteTemplate(minifyWriter, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(responseData); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
+}
+
+// HomePageData lists the featured projects shown on the home page
+func (ph *ProjectsHandler) HomePageData(r *http.Request) (interface{}, error) {
+	return struct {
+		Projects []models.Project
+	}{
+		Projects: ph.Catalog.GetFeatured(),
+	}, nil
+}
 
-	err = minifyWriter.Flush()
-	if err != nil {
+// parseProjectFilter builds a ProjectFilter from query parameters
+func parseProjectFilter(r *http.Request) models.ProjectFilter {
+	query := r.URL.Query()
+	return models.ProjectFilter{
+		Query:    strings.TrimSpace(query.Get("q")),
+		Status:   query.Get("status"),
+		Language: query.Get("language"),
+		Category: query.Get("category"),
+		Tech:     query.Get("tech"),
 	}
 }
@@ -1,45 +1,45 @@
 package handlers
 
 import (
-	"html/template"
 	"net/http"
+	"time"
 
-	"github.com/0x800a6/www/internal/middleware"
 	"github.com/0x800a6/www/internal/models"
-	"github.com/0x800a6/www/internal/utils"
 )
 
-func RateLimitHandler(w http.ResponseWriter, r *http.Request, tmplData models.TemplateData) {
-	data := tmplData
-	data.Page = models.PageData{
-		Title:   "Rate Limit Exceeded",
-		Content: "ratelimit",
-	}
-
-	allTmpl, err := template.ParseGlob(utils.GetTemplatePath("templates/*.html"))
-	if err != nil {
-		http.Error(w, "Template parsing error", http.StatusInternalServerError)
-		return
-	}
-
-	allTmpl, err = allTmpl.ParseFiles(utils.GetTemplatePath("html/ratelimit.html"))
-	if err != nil {
-		http.Error(w, "Page not found", http.StatusNotFound)
-		return
-	}
-
-	w.WriteHeader(http.StatusTooManyRequests)
+// RateLimitHandler tells visitors of the rate limit page about the penalty
+// their key is serving, if any
+type RateLimitHandler struct {
+	escalator *models.Escalator
+	key       func(r *http.Request) string
+}
 
-	minifyWriter := middleware.NewMinifyResponseWriter(w)
+func NewRateLimitHandler(escalator *models.Escalator, key func(r *http.Request) string) *RateLimitHandler {
+	return &RateLimitHandler{escalator: escalator, key: key}
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err = allTmpl.ExecuteTemplate(minifyWriter, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+// RateLimitPageData is nil-safe in templates: Penalized is false for
+// visitors that were only refused by their regular limit, and Manual is set
+// for bans placed from the admin dashboard
+type RateLimitPageData struct {
+	Penalized bool
+	Manual    bool
+	Level     int
+	Until     time.Time
+	Remaining time.Duration
+}
 
-	err = minifyWriter.Flush()
-	if err != nil {
+// PageData is the DataFunc of the rate limit page
+func (rh *RateLimitHandler) PageData(r *http.Request) (interface{}, error) {
+	data := &RateLimitPageData{}
+
+	penalty, ok := rh.escalator.Penalty(rh.key(r))
+	if ok {
+		data.Penalized = true
+		data.Manual = penalty.Manual
+		data.Level = penalty.Level
+		data.Until = penalty.Until
+		data.Remaining = penalty.Remaining()
 	}
+	return data, nil
 }
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/0x800a6/www/internal/models"
+)
+
+// adminKeyLimit caps the keys listed in each table of the dashboard
+const adminKeyLimit = 50
+
+// RateLimitAdminHandler serves the rate limiter dashboard, its JSON API and
+// the reset and ban actions. It expects to be mounted behind authentication.
+type RateLimitAdminHandler struct {
+	limiter   models.Limiter
+	escalator *models.Escalator
+}
+
+func NewRateLimitAdminHandler(limiter models.Limiter, escalator *models.Escalator) *RateLimitAdminHandler {
+	return &RateLimitAdminHandler{limiter: limiter, escalator: escalator}
+}
+
+// RateLimitAdminData is the dashboard state, also served as JSON
+type RateLimitAdminData struct {
+	Stats     models.LimiterStats `json:"stats"`
+	TopKeys   []models.KeyState   `json:"top_keys"`
+	Throttled []models.KeyState   `json:"throttled"`
+	Bans      []models.Ban        `json:"bans"`
+	Generated time.Time           `json:"generated"`
+}
+
+// RejectionChart is a rejection series scaled for the dashboard bar chart
+type RejectionChart struct {
+	Policy string
+	Total  uint64
+	Bars   []RejectionBar
+}
+
+type RejectionBar struct {
+	Minute  time.Time
+	Count   uint64
+	Percent int
+}
+
+// Charts scales every policy's rejections against the busiest minute of all
+// policies, so the charts can be compared
+func (d *RateLimitAdminData) Charts() []RejectionChart {
+	var peak uint64 = 1
+	for _, series := range d.Stats.Rejections {
+		for _, count := range series.Counts {
+			peak = max(peak, count)
+		}
+	}
+
+	charts := []RejectionChart{}
+	for _, series := range d.Stats.Rejections {
+		chart := RejectionChart{Policy: series.Policy}
+		for i, count := range series.Counts {
+			chart.Total += count
+			chart.Bars = append(chart.Bars, RejectionBar{
+				Minute:  series.Start.Add(time.Duration(i) * time.Minute),
+				Count:   count,
+				Percent: int(count * 100 / peak),
+			})
+		}
+		charts = append(charts, chart)
+	}
+	return charts
+}
+
+func (ah *RateLimitAdminHandler) data() (*RateLimitAdminData, error) {
+	stats, err := ah.limiter.Stats()
+	if err != nil {
+		return nil, err
+	}
+	inspection, err := ah.limiter.Inspect()
+	if err != nil {
+		return nil, err
+	}
+
+	data := &RateLimitAdminData{
+		Stats:     stats,
+		TopKeys:   inspection.Keys[:min(len(inspection.Keys), adminKeyLimit)],
+		Throttled: []models.KeyState{},
+		Bans:      inspection.Bans,
+		Generated: time.Now(),
+	}
+	for _, key := range inspection.Keys {
+		if key.Throttled && len(data.Throttled) < adminKeyLimit {
+			data.Throttled = append(data.Throttled, key)
+		}
+	}
+	return data, nil
+}
+
+// PageData is the DataFunc of the dashboard page
+func (ah *RateLimitAdminHandler) PageData(r *http.Request) (interface{}, error) {
+	return ah.data()
+}
+
+func (ah *RateLimitAdminHandler) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	data, err := ah.data()
+	if err != nil {
+		log.Printf("admin: %v", err)
+		http.Error(w, "Rate limiter unavailable", http.StatusBadGateway)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, data)
+}
+
+// ServeReset forgets the key under every policy, lifts its ban and clears
+// its escalation
+func (ah *RateLimitAdminHandler) ServeReset(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.FormValue("key"))
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	inspection, err := ah.limiter.Inspect()
+	if err == nil {
+		for _, state := range inspection.Keys {
+			if state.Key == key && err == nil {
+				err = ah.limiter.Reset(state.Policy, key)
+			}
+		}
+	}
+	if err == nil {
+		err = ah.limiter.Unban(key)
+	}
+	if err != nil {
+		log.Printf("admin: reset %q: %v", key, err)
+		http.Error(w, "Rate limiter unavailable", http.StatusBadGateway)
+		return
+	}
+	ah.escalator.Forgive(key)
+
+	log.Printf("admin: reset rate limits of %q", key)
+	ah.done(w, r, map[string]string{"status": "reset", "key": key})
+}
+
+// ServeBan refuses every request of the key for the given duration
+func (ah *RateLimitAdminHandler) ServeBan(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.FormValue("key"))
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	duration, err := time.ParseDuration(r.FormValue("duration"))
+	if err != nil || duration <= 0 {
+		http.Error(w, "duration must be a positive duration such as 15m or 24h", http.StatusBadRequest)
+		return
+	}
+
+	// The escalator records the ban so the key is shown it on the rate
+	// limit page
+	penalty, err := ah.escalator.Ban(key, duration)
+	if err != nil {
+		log.Printf("admin: ban %q: %v", key, err)
+		http.Error(w, "Rate limiter unavailable", http.StatusBadGateway)
+		return
+	}
+
+	log.Printf("admin: banned %q for %s", key, duration)
+	ah.done(w, r, map[string]string{"status": "banned", "key": key, "until": penalty.Until.Format(time.RFC3339)})
+}
+
+// done sends browsers submitting the dashboard forms back to it, and API
+// clients the outcome as JSON
+func (ah *RateLimitAdminHandler) done(w http.ResponseWriter, r *http.Request, outcome map[string]string) {
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, "/admin/ratelimit", http.StatusSeeOther)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, outcome)
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(data)
+}
@@ -1,43 +1,68 @@
 package handlers
 
 import (
-	"html/template"
+	"encoding/json"
 	"net/http"
 
-	"github.com/0x800a6/www/internal/middleware"
 	"github.com/0x800a6/www/internal/models"
-	"github.com/0x800a6/www/internal/utils"
 )
 
-func ResumeHandler(w http.ResponseWriter, r *http.Request, tmplData models.TemplateData) {
-	data := tmplData
-	data.Page = models.PageData{
-		Title:   "Resume",
-		Content: "resume",
-	}
+// resumeTextWidth is the column plain text exports are wrapped at
+const resumeTextWidth = 80
 
-	allTmpl, err := template.ParseGlob(utils.GetTemplatePath("templates/*.html"))
-	if err != nil {
-		http.Error(w, "Template parsing error", http.StatusInternalServerError)
-		return
-	}
+// ResumeHandler serves the resume page and its machine-readable exports
+type ResumeHandler struct {
+	Resume  *models.Resume
+	Catalog *models.ProjectCatalog
+}
 
-	allTmpl, err = allTmpl.ParseFiles(utils.GetTemplatePath("html/resume.html"))
-	if err != nil {
-		http.Error(w, "Page not found", http.StatusNotFound)
-		return
+func NewResumeHandler(resume *models.Resume, catalog *models.ProjectCatalog) *ResumeHandler {
+	return &ResumeHandler{
+		Resume:  resume,
+		Catalog: catalog,
 	}
+}
+
+// document returns the resume with the catalog's resume projects included
+func (rh *ResumeHandler) document() *models.Resume {
+	return rh.Resume.WithProjects(rh.Catalog.GetResumeProjects())
+}
 
-	minifyWriter := middleware.NewMinifyResponseWriter(w)
+// PageData exposes the resume to the resume page
+func (rh *ResumeHandler) PageData(r *http.Request) (interface{}, error) {
+	resume := rh.document()
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err = allTmpl.ExecuteTemplate(minifyWriter, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	return struct {
+		Resume     *models.Resume
+		Projects   []models.ResumeProject
+		OpenSource []models.ResumeProject
+	}{
+		Resume:     resume,
+		Projects:   resume.GetProjectsByType("application"),
+		OpenSource: resume.GetProjectsByType("open-source"),
+	}, nil
+}
+
+// ServeJSON returns the resume as a JSON Resume document
+func (rh *ResumeHandler) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(rh.document()); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
+}
 
-	err = minifyWriter.Flush()
-	if err != nil {
-	}
+// ServeText returns the resume as plain text
+func (rh *ResumeHandler) ServeText(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(rh.document().PlainText(resumeTextWidth)))
+}
+
+// ServeMarkdown returns the resume as Markdown
+func (rh *ResumeHandler) ServeMarkdown(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Write([]byte(rh.document().Markdown()))
 }
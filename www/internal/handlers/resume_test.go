@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/0x800a6/www/internal/models"
+	"github.com/0x800a6/www/internal/templates"
+)
+
+// testResume fills every JSON Resume section, each with an entry whose text
+// only appears in that section
+func testResume() (*models.Resume, map[string]string) {
+	resume := &models.Resume{
+		Basics: models.ResumeBasics{
+			Name:    "Ada Lovelace",
+			Label:   "Engineer",
+			Email:   "ada@example.org",
+			Summary: "Writes programs for engines that do not exist yet.",
+		},
+		Work: []models.ResumeWork{{
+			Name:       "Analytical Engines Ltd",
+			Position:   "Programmer",
+			StartDate:  "2020-01",
+			Highlights: []string{"Wrote the first loop"},
+		}},
+		Volunteer: []models.ResumeVolunteer{{
+			Organization: "Code Club Volunteers",
+			Position:     "Mentor",
+		}},
+		Education: []models.ResumeEducation{{
+			Institution: "University of London",
+			Area:        "Mathematics",
+			StudyType:   "Bachelor",
+			EndDate:     "2019-06",
+			Courses:     []string{"Differential Calculus"},
+		}},
+		Awards:       []models.ResumeAward{{Title: "Best Paper Award", Awarder: "Royal Society"}},
+		Certificates: []models.ResumeCertificate{{Name: "Certified Gopher", Issuer: "Go Team"}},
+		Publications: []models.ResumePublication{{Name: "Notes on the Engine", ReleaseDate: "1843"}},
+		Skills:       []models.ResumeSkill{{Name: "Programming Languages", Keywords: []string{"Golang"}}},
+		Languages:    []models.ResumeLanguage{{Language: "French", Fluency: "Fluent"}},
+		Interests:    []models.ResumeInterest{{Name: "Poetry", Summary: "Poetical science"}},
+		References:   []models.ResumeReference{{Name: "Charles Babbage", Reference: "The enchantress of numbers"}},
+		Projects:     []models.ResumeProject{{Name: "Difference Engine", Type: "application", Description: "A calculator"}},
+	}
+
+	markers := map[string]string{
+		"basics":       "Writes programs for engines",
+		"work":         "Analytical Engines Ltd",
+		"volunteer":    "Code Club Volunteers",
+		"education":    "University of London",
+		"awards":       "Best Paper Award",
+		"certificates": "Certified Gopher",
+		"publications": "Notes on the Engine",
+		"skills":       "Golang",
+		"languages":    "French",
+		"interests":    "Poetry",
+		"references":   "Charles Babbage",
+		"projects":     "Difference Engine",
+	}
+	return resume, markers
+}
+
+// TestResumeFormatsCoverEverySection checks that the resume page and every
+// export render all the sections of the resume
+func TestResumeFormatsCoverEverySection(t *testing.T) {
+	resume, markers := testResume()
+	handler := NewResumeHandler(resume, &models.ProjectCatalog{})
+
+	if len(resume.Sections()) != 8 {
+		t.Fatalf("%d sections beside the basics, skills, projects and interests, want 8", len(resume.Sections()))
+	}
+
+	// Templates are looked up relative to the www directory
+	t.Chdir("../..")
+	registry := templates.NewRegistry(false, template.FuncMap{"asset": func(name string) string { return "/static/" + name }})
+	renderer := NewPageRenderer(registry, models.TemplateData{})
+	page := renderer.Handler(Page{Path: "/resume", File: "resume.html", Title: "Resume", Data: handler.PageData})
+
+	formats := []struct {
+		name    string
+		handler http.Handler
+		// heading formats a section title the way the format shows it
+		heading func(title string) string
+	}{
+		{"html", page, func(title string) string { return ">" + title + "</span>" }},
+		{"text", http.HandlerFunc(handler.ServeText), func(title string) string { return "\n" + strings.ToUpper(title) + "\n" }},
+		{"markdown", http.HandlerFunc(handler.ServeMarkdown), func(title string) string { return "\n## " + title + "\n" }},
+		{"json", http.HandlerFunc(handler.ServeJSON), nil},
+	}
+
+	for _, format := range formats {
+		t.Run(format.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			format.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resume", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("answered %d: %s", rec.Code, rec.Body.String())
+			}
+			body := rec.Body.String()
+
+			for section, marker := range markers {
+				if !strings.Contains(body, marker) {
+					t.Errorf("%s section missing, no %q", section, marker)
+				}
+			}
+			if format.heading == nil {
+				return
+			}
+			for _, section := range resume.Sections() {
+				if !strings.Contains(body, format.heading(section.Title)) {
+					t.Errorf("no %s heading", section.Title)
+				}
+				for _, entry := range section.Entries {
+					for _, item := range entry.Items {
+						if !strings.Contains(body, item) {
+							t.Errorf("%s item %q missing", section.Title, item)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestResumeSections(t *testing.T) {
+	resume, _ := testResume()
+	sections := resume.Sections()
+
+	want := []struct {
+		title string
+		entry models.ResumeEntry
+	}{
+		{"Experience", models.ResumeEntry{Title: "Programmer, Analytical Engines Ltd", Date: "2020-01 - Present", Items: []string{"Wrote the first loop"}}},
+		{"Education", models.ResumeEntry{Title: "Bachelor in Mathematics, University of London", Date: "2019-06", Items: []string{"Differential Calculus"}}},
+		{"Volunteering", models.ResumeEntry{Title: "Mentor, Code Club Volunteers"}},
+		{"Awards", models.ResumeEntry{Title: "Best Paper Award", Subtitle: "Royal Society"}},
+		{"Certificates", models.ResumeEntry{Title: "Certified Gopher", Subtitle: "Go Team"}},
+		{"Publications", models.ResumeEntry{Title: "Notes on the Engine", Date: "1843"}},
+		{"Languages", models.ResumeEntry{Title: "French", Subtitle: "Fluent"}},
+		{"References", models.ResumeEntry{Title: "Charles Babbage", Summary: "The enchantress of numbers"}},
+	}
+	if len(sections) != len(want) {
+		t.Fatalf("%d sections, want %d", len(sections), len(want))
+	}
+	for i, w := range want {
+		got := sections[i]
+		if got.Title != w.title || len(got.Entries) != 1 {
+			t.Errorf("section %d is %s with %d entries, want %s with 1", i, got.Title, len(got.Entries), w.title)
+			continue
+		}
+		entry := got.Entries[0]
+		if entry.Title != w.entry.Title || entry.Subtitle != w.entry.Subtitle || entry.Date != w.entry.Date || entry.Summary != w.entry.Summary || strings.Join(entry.Items, "|") != strings.Join(w.entry.Items, "|") {
+			t.Errorf("%s entry %+v, want %+v", w.title, entry, w.entry)
+		}
+	}
+
+	// Empty sections are left out
+	if sections := (&models.Resume{Basics: resume.Basics}).Sections(); len(sections) != 0 {
+		t.Errorf("resume without sections has %d", len(sections))
+	}
+}
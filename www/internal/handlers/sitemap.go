@@ -3,61 +3,63 @@ package handlers
 import (
 	"encoding/xml"
 	"fmt"
-	"html/template"
 	"net/http"
 	"time"
 
-	"github.com/0x800a6/www/internal/middleware"
 	"github.com/0x800a6/www/internal/models"
 	"github.com/0x800a6/www/internal/utils"
 )
 
+// SitemapHandler lists the site's pages, all last modified at the time
+// modTime reports, so the sitemap only changes when the pages do
 type SitemapHandler struct {
 	BaseURL string
+	modTime func() time.Time
 }
 
-func NewSitemapHandler(baseURL string) *SitemapHandler {
+func NewSitemapHandler(baseURL string, modTime func() time.Time) *SitemapHandler {
 	return &SitemapHandler{
 		BaseURL: baseURL,
+		modTime: modTime,
 	}
 }
 
 func (sh *SitemapHandler) generateSitemap() *models.Sitemap {
-	now := time.Now()
+	lastMod := sh.modTime().UTC()
 
 	pages := []models.SitePage{
 		{
 			Path:       "/",
 			Title:      "Home",
-			LastMod:    now,
+			LastMod:    lastMod,
 			ChangeFreq: "weekly",
 			Priority:   "1.0",
 		},
 		{
 			Path:       "/sitemap",
 			Title:      "Sitemap",
-			LastMod:    now,
+			LastMod:    lastMod,
 			ChangeFreq: "monthly",
 			Priority:   "0.5",
 		},
 		{
 			Path:       "/resume",
 			Title:      "Resume",
-			LastMod:    now,
+			LastMod:    lastMod,
 			ChangeFreq: "monthly",
 			Priority:   "0.7",
 		},
 		{
 			Path:       "/projects",
 			Title:      "Projects",
-			LastMod:    now,
+			LastMod:    lastMod,
 			ChangeFreq: "weekly",
 			Priority:   "0.8",
 		},
 		{
 			Path:       "/changelog",
 			Title:      "Changelog",
-			LastMod:    now,
+			LastMod:    lastMod,
 			ChangeFreq: "weekly",
 			Priority:   "0.7",
 		},
@@ -94,7 +96,8 @@ func (sh *SitemapHandler) ServeXML(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (sh *SitemapHandler) ServePage(w http.ResponseWriter, r *http.Request, tmplData models.TemplateData) {
+// PageData lists the sitemap entries for the human-readable sitemap page
+func (sh *SitemapHandler) PageData(r *http.Request) (interface{}, error) {
 	sitemap := sh.generateSitemap()
 
 	var pages []models.SitePage
@@ -118,35 +121,5 @@ func (sh *SitemapHandler) ServePage(w http.ResponseWriter, r *http.Request, tmpl
 		})
 	}
 
-	data := tmplData
-	data.Page = models.PageData{
-		Title:   "Sitemap",
-		Content: "sitemap",
-		Data:    pages,
-	}
-
-	allTmpl, err := template.ParseGlob(utils.GetTemplatePath("templates/*.html"))
-	if err != nil {
-		http.Error(w, "Template parsing error", http.StatusInternalServerError)
-		return
-	}
-
-	allTmpl, err = allTmpl.ParseFiles(utils.GetTemplatePath("html/sitemap.html"))
-	if err != nil {
-		http.Error(w, "Page not found", http.StatusNotFound)
-		return
-	}
-
-	minifyWriter := middleware.NewMinifyResponseWriter(w)
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err = allTmpl.ExecuteTemplate(minifyWriter, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = minifyWriter.Flush()
-	if err != nil {
-	}
+	return pages, nil
 }
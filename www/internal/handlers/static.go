@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/0x800a6/www/internal/middleware"
+	"github.com/0x800a6/www/internal/models"
+)
+
+// StaticHandler serves the asset pipeline's output under /static/. Files it
+// does not know, such as every file in dev mode, go to fallback.
+type StaticHandler struct {
+	assets   *models.AssetStore
+	fallback http.Handler
+}
+
+func NewStaticHandler(assets *models.AssetStore, fallback http.Handler) *StaticHandler {
+	return &StaticHandler{assets: assets, fallback: fallback}
+}
+
+// ServeHTTP expects the /static/ prefix to be stripped already
+func (sh *StaticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	asset, ok := sh.assets.Get(strings.TrimPrefix(r.URL.Path, "/"))
+	if !ok {
+		sh.fallback.ServeHTTP(w, r)
+		return
+	}
+
+	if len(asset.Encoded) > 0 && !varies(w.Header(), "Accept-Encoding") {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if asset.Fingerprinted {
+		// The name changes with the content, so the file can be kept forever
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+
+	body, etag := asset.Content, asset.Hash
+	encoding := middleware.NegotiateEncoding(r.Header.Get("Accept-Encoding"), "br", "gzip")
+	if encoded, exists := asset.Encoded[encoding]; exists {
+		body, etag = encoded, asset.Hash+"-"+encoding
+		w.Header().Set("Content-Encoding", encoding)
+	}
+
+	w.Header().Set("Content-Type", asset.ContentType)
+	w.Header().Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, asset.Name, asset.ModTime, bytes.NewReader(body))
+}
+
+func varies(header http.Header, name string) bool {
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// Handler serves the registered metrics. When token is set, scrapes must send
+// it as a bearer token.
+func Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			given := []byte(r.Header.Get("Authorization"))
+			expected := []byte("Bearer " + token)
+			if subtle.ConstantTimeCompare(given, expected) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		WriteTo(w)
+	})
+}
@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func lines(output string) []string {
+	return strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+}
+
+func TestCounterWrite(t *testing.T) {
+	counter := NewCounter("test_counter_total", "Counts \\ things\nover lines", "route", "method")
+	counter.Inc("/b", "GET")
+	counter.Add(2.5, "/a", "POST")
+	counter.Inc("/b", "GET")
+	counter.Inc(`say "hi"`+"\n"+`C:\path`, "GET")
+
+	var buffer bytes.Buffer
+	counter.write(&buffer)
+
+	want := []string{
+		`# HELP test_counter_total Counts \\ things\nover lines`,
+		`# TYPE test_counter_total counter`,
+		`test_counter_total{route="/a",method="POST"} 2.5`,
+		`test_counter_total{route="/b",method="GET"} 2`,
+		`test_counter_total{route="say \"hi\"\nC:\\path",method="GET"} 1`,
+	}
+	if got := lines(buffer.String()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("counter output\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	counter := NewCounter("test_plain_total", "Counts")
+
+	var buffer bytes.Buffer
+	counter.write(&buffer)
+	if !strings.HasSuffix(buffer.String(), "\ntest_plain_total 0\n") {
+		t.Errorf("unused counter without labels is not reported as 0:\n%s", buffer.String())
+	}
+
+	counter.Add(3)
+	buffer.Reset()
+	counter.write(&buffer)
+	if !strings.HasSuffix(buffer.String(), "\ntest_plain_total 3\n") {
+		t.Errorf("counter without labels:\n%s", buffer.String())
+	}
+}
+
+func TestHistogramWrite(t *testing.T) {
+	histogram := NewHistogram("test_duration_seconds", "Durations", []float64{0.1, 0.5, 1}, "route")
+	for _, value := range []float64{0.05, 0.1, 0.3, 0.7, 5} {
+		histogram.Observe(value, "/")
+	}
+	histogram.Observe(0.2, `a"b`)
+
+	var buffer bytes.Buffer
+	histogram.write(&buffer)
+
+	want := []string{
+		`# HELP test_duration_seconds Durations`,
+		`# TYPE test_duration_seconds histogram`,
+		// Buckets are cumulative and the bound is inclusive
+		`test_duration_seconds_bucket{route="/",le="0.1"} 2`,
+		`test_duration_seconds_bucket{route="/",le="0.5"} 3`,
+		`test_duration_seconds_bucket{route="/",le="1"} 4`,
+		`test_duration_seconds_bucket{route="/",le="+Inf"} 5`,
+		`test_duration_seconds_sum{route="/"} 6.15`,
+		`test_duration_seconds_count{route="/"} 5`,
+		`test_duration_seconds_bucket{route="a\"b",le="0.1"} 0`,
+		`test_duration_seconds_bucket{route="a\"b",le="0.5"} 1`,
+		`test_duration_seconds_bucket{route="a\"b",le="1"} 1`,
+		`test_duration_seconds_bucket{route="a\"b",le="+Inf"} 1`,
+		`test_duration_seconds_sum{route="a\"b"} 0.2`,
+		`test_duration_seconds_count{route="a\"b"} 1`,
+	}
+	if got := lines(buffer.String()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("histogram output\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGaugeFuncWrite(t *testing.T) {
+	value := 1.5
+	gauge := NewGaugeFunc("test_gauge", "A gauge", func() float64 { return value })
+
+	for _, tt := range []struct {
+		value float64
+		want  string
+	}{
+		{1.5, "test_gauge 1.5"},
+		{math.Inf(1), "test_gauge +Inf"},
+		{math.NaN(), "test_gauge NaN"},
+		{1e21, "test_gauge 1e+21"},
+	} {
+		value = tt.value
+		var buffer bytes.Buffer
+		gauge.write(&buffer)
+		if got := lines(buffer.String()); got[len(got)-1] != tt.want {
+			t.Errorf("gauge of %v written as %q, want %q", tt.value, got[len(got)-1], tt.want)
+		}
+	}
+}
+
+func TestWriteToHeadersOncePerFamily(t *testing.T) {
+	counter := NewCounter("test_family_total", "Family", "code")
+	counter.Inc("200")
+	counter.Inc("404")
+	histogram := NewHistogram("test_family_seconds", "Family", DefaultBuckets, "code")
+	histogram.Observe(1, "200")
+	histogram.Observe(1, "404")
+
+	var buffer bytes.Buffer
+	WriteTo(&buffer)
+
+	help := map[string]int{}
+	types := map[string]int{}
+	for _, line := range lines(buffer.String()) {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "# HELP "):
+			help[fields[2]]++
+		case strings.HasPrefix(line, "# TYPE "):
+			types[fields[2]]++
+		case line == "" || strings.HasPrefix(line, "#"):
+			t.Errorf("unexpected line %q", line)
+		}
+	}
+
+	if len(help) == 0 {
+		t.Fatal("no metric families written")
+	}
+	for name, count := range help {
+		if count != 1 || types[name] != 1 {
+			t.Errorf("%s has %d HELP and %d TYPE lines, want one of each", name, count, types[name])
+		}
+	}
+	for _, name := range []string{"test_family_total", "test_family_seconds", "www_http_requests_total"} {
+		if help[name] == 0 {
+			t.Errorf("%s was not written", name)
+		}
+	}
+}
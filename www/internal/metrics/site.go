@@ -0,0 +1,14 @@
+package metrics
+
+// Metrics recorded across the website. Gauges that read live state are
+// registered by main with NewGaugeFunc.
+var (
+	HTTPRequests         = NewCounter("www_http_requests_total", "HTTP requests by route pattern, method and status.", "route", "method", "status")
+	HTTPRequestDuration  = NewHistogram("www_http_request_duration_seconds", "HTTP request latency by route pattern and status.", DefaultBuckets, "route", "status")
+	RateLimitRejections  = NewCounter("www_ratelimit_rejections_total", "Requests refused by the rate limiter, by policy.", "policy")
+	RateLimitDenied      = NewCounter("www_ratelimit_denied_total", "Requests refused by the rate limit denylist.")
+	RateLimitPenalties   = NewCounter("www_ratelimit_penalties_total", "Bans issued to keys that kept exceeding their limit.")
+	TemplateRenderErrors = NewCounter("www_template_render_errors_total", "Page renders that failed, by template.", "template")
+	MinifyFailures       = NewCounter("www_minify_failures_total", "Responses sent unminified because minification failed.")
+	ChangelogReloads     = NewCounter("www_changelog_reloads_total", "Changelog loads by result.", "result")
+)
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+type accessLogKey struct{}
+
+// accessLogFields collects values that inner middleware learns about a
+// request, such as the rate limit key, so they end up on the access log line
+type accessLogFields struct {
+	requestID string
+	key       string
+	remaining int
+}
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// AccessLogMiddleware logs one line per request. It must be the outermost
+// middleware so the status and size match what was sent.
+func AccessLogMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			// Keep the proxy's request ID so log lines can be correlated
+			requestID := r.Header.Get("X-Request-ID")
+			if !requestIDPattern.MatchString(requestID) {
+				requestID = generateUserID()
+			}
+			w.Header().Set("X-Request-ID", requestID)
+
+			fields := &accessLogFields{requestID: requestID, remaining: -1}
+			r = r.WithContext(context.WithValue(r.Context(), accessLogKey{}, fields))
+
+			sw := &statusResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			status := sw.Status()
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			attrs := []slog.Attr{
+				slog.String("request_id", fields.requestID),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", sw.bytes),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if fields.key != "" {
+				attrs = append(attrs, slog.String("ratelimit_key", fields.key))
+			}
+			if fields.remaining >= 0 {
+				attrs = append(attrs, slog.Int("remaining", fields.remaining))
+			}
+
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
+
+// setAccessLogRateLimit records the rate limit key and remaining tokens of
+// the request for the access log, if it is enabled
+func setAccessLogRateLimit(r *http.Request, key string, remaining int) {
+	if fields, ok := r.Context().Value(accessLogKey{}).(*accessLogFields); ok {
+		fields.key = key
+		fields.remaining = remaining
+	}
+}
+
+// RequestID returns the ID the access log assigned to the request
+func RequestID(r *http.Request) string {
+	if fields, ok := r.Context().Value(accessLogKey{}).(*accessLogFields); ok {
+		return fields.requestID
+	}
+	return ""
+}
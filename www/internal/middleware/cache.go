@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// ResponseCache keeps successful GET responses in memory, evicting the least
+// recently used entries once the total body size exceeds maxBytes
+type ResponseCache struct {
+	maxBytes int
+	size     int
+	entries  map[string]*list.Element
+	order    *list.List
+	mutex    sync.Mutex
+}
+
+type cachedResponse struct {
+	key    string
+	header http.Header
+	body   []byte
+}
+
+// cacheResponseWriter gives the handler its own header map so headers set by
+// outer middleware, such as the rate limit cookie, are never cached
+type cacheResponseWriter struct {
+	header http.Header
+	status int
+	buffer bytes.Buffer
+}
+
+func (crw *cacheResponseWriter) Header() http.Header {
+	return crw.header
+}
+
+func (crw *cacheResponseWriter) WriteHeader(status int) {
+	crw.status = status
+}
+
+func (crw *cacheResponseWriter) Write(data []byte) (int, error) {
+	return crw.buffer.Write(data)
+}
+
+func NewResponseCache(maxBytes int) *ResponseCache {
+	return &ResponseCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (rc *ResponseCache) get(key string) *cachedResponse {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	element, exists := rc.entries[key]
+	if !exists {
+		return nil
+	}
+	rc.order.MoveToFront(element)
+	return element.Value.(*cachedResponse)
+}
+
+func (rc *ResponseCache) put(entry *cachedResponse) {
+	if len(entry.body) > rc.maxBytes {
+		return
+	}
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if element, exists := rc.entries[entry.key]; exists {
+		rc.remove(element)
+	}
+
+	rc.entries[entry.key] = rc.order.PushFront(entry)
+	rc.size += len(entry.body)
+
+	for rc.size > rc.maxBytes {
+		rc.remove(rc.order.Back())
+	}
+}
+
+func (rc *ResponseCache) remove(element *list.Element) {
+	entry := rc.order.Remove(element).(*cachedResponse)
+	delete(rc.entries, entry.key)
+	rc.size -= len(entry.body)
+}
+
+// Invalidate drops every cached response
+func (rc *ResponseCache) Invalidate() {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	rc.entries = make(map[string]*list.Element)
+	rc.order.Init()
+	rc.size = 0
+}
+
+// Len returns the number of cached responses
+func (rc *ResponseCache) Len() int {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	return rc.order.Len()
+}
+
+func CacheMiddleware(cache *ResponseCache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := cacheKey(r.URL)
+
+			if entry := cache.get(key); entry != nil {
+				copyHeader(w.Header(), entry.header)
+				w.Header().Set("X-Cache", "HIT")
+				w.WriteHeader(http.StatusOK)
+				w.Write(entry.body)
+				return
+			}
+
+			crw := &cacheResponseWriter{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(crw, r)
+
+			copyHeader(w.Header(), crw.header)
+
+			// Only complete successful responses are reusable, HEAD
+			// requests carry no body worth storing
+			if crw.status == http.StatusOK && r.Method == http.MethodGet {
+				cache.put(&cachedResponse{
+					key:    key,
+					header: crw.header.Clone(),
+					body:   bytes.Clone(crw.buffer.Bytes()),
+				})
+				w.Header().Set("X-Cache", "MISS")
+			}
+
+			w.WriteHeader(crw.status)
+			w.Write(crw.buffer.Bytes())
+		})
+	}
+}
+
+// cacheKey normalizes the query so parameter order and empty parameters do
+// not create separate entries
+func cacheKey(u *url.URL) string {
+	query := u.Query()
+	for name, values := range query {
+		kept := values[:0]
+		for _, value := range values {
+			if value != "" {
+				kept = append(kept, value)
+			}
+		}
+		if len(kept) == 0 {
+			query.Del(name)
+			continue
+		}
+		query[name] = kept
+	}
+
+	if len(query) == 0 {
+		return u.Path
+	}
+	return u.Path + "?" + query.Encode()
+}
+
+func copyHeader(dst, src http.Header) {
+	for name, values := range src {
+		dst[name] = append([]string(nil), values...)
+	}
+}
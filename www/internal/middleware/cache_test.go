@@ -0,0 +1,298 @@
+package middleware
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/0x800a6/www/internal/models"
+	"github.com/0x800a6/www/internal/templates"
+)
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/changelog", "/changelog"},
+		{"/changelog?", "/changelog"},
+		{"/changelog?type=Added&x=1", "/changelog?type=Added&x=1"},
+		{"/changelog?x=1&type=Added", "/changelog?type=Added&x=1"},
+		{"/changelog?type=&x=1", "/changelog?x=1"},
+		{"/changelog?type=", "/changelog"},
+		{"/changelog?type=Added&type=&type=Fixed", "/changelog?type=Added&type=Fixed"},
+		{"/changelog?q=a+b", "/changelog?q=a+b"},
+		{"/changelog?q=a%20b", "/changelog?q=a+b"},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cacheKey(u); got != tt.want {
+			t.Errorf("cacheKey(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+// countingHandler answers every path with a body naming the path and how many
+// times the handler ran
+func countingHandler(calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		switch r.URL.Path {
+		case "/missing":
+			http.Error(w, "missing", http.StatusNotFound)
+			return
+		case "/large":
+			io.WriteString(w, strings.Repeat("x", 64))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "%s %d", r.URL.Path, *calls)
+	})
+}
+
+func serveCached(handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	calls := 0
+	cache := NewResponseCache(1024)
+	handler := CacheMiddleware(cache)(countingHandler(&calls))
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		wantCache string
+		wantBody  string
+		wantCalls int
+	}{
+		{name: "first request", target: "/changelog?type=Added&x=1", wantCache: "MISS", wantBody: "/changelog 1", wantCalls: 1},
+		{name: "same request", target: "/changelog?type=Added&x=1", wantCache: "HIT", wantBody: "/changelog 1", wantCalls: 1},
+		{name: "reordered query", target: "/changelog?x=1&type=Added", wantCache: "HIT", wantBody: "/changelog 1", wantCalls: 1},
+		{name: "empty parameter", target: "/changelog?x=1&type=Added&page=", wantCache: "HIT", wantBody: "/changelog 1", wantCalls: 1},
+		{name: "other query", target: "/changelog?type=Fixed", wantCache: "MISS", wantBody: "/changelog 2", wantCalls: 2},
+		{name: "head of cached page", method: http.MethodHead, target: "/changelog?type=Fixed", wantCache: "HIT", wantCalls: 2},
+		{name: "head of new page", method: http.MethodHead, target: "/about", wantCalls: 3},
+		{name: "head not stored", target: "/about", wantCache: "MISS", wantBody: "/about 4", wantCalls: 4},
+		{name: "post", method: http.MethodPost, target: "/about", wantBody: "/about 5", wantCalls: 5},
+		{name: "error", target: "/missing", wantBody: "missing\n", wantCalls: 6},
+		{name: "error not stored", target: "/missing", wantBody: "missing\n", wantCalls: 7},
+	}
+
+	for _, tt := range tests {
+		method := tt.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		rec := serveCached(handler, method, tt.target)
+
+		if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+			t.Errorf("%s: X-Cache %q, want %q", tt.name, got, tt.wantCache)
+		}
+		if method != http.MethodHead && rec.Body.String() != tt.wantBody {
+			t.Errorf("%s: body %q, want %q", tt.name, rec.Body.String(), tt.wantBody)
+		}
+		if calls != tt.wantCalls {
+			t.Errorf("%s: handler ran %d times, want %d", tt.name, calls, tt.wantCalls)
+		}
+	}
+
+	if cache.Len() != 3 {
+		t.Errorf("cached %d responses, want 3", cache.Len())
+	}
+}
+
+func TestCacheMiddlewareHeaders(t *testing.T) {
+	cache := NewResponseCache(1024)
+	inner := CacheMiddleware(cache)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "page")
+	}))
+	// The outer layer stands in for per-client headers such as the rate
+	// limit cookie, which must never be replayed to another client
+	client := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client++
+		w.Header().Set("X-Client", fmt.Sprint(client))
+		inner.ServeHTTP(w, r)
+	})
+
+	serveCached(handler, http.MethodGet, "/")
+	rec := serveCached(handler, http.MethodGet, "/")
+
+	if rec.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("second request X-Cache %q, want HIT", rec.Header().Get("X-Cache"))
+	}
+	if got := rec.Header().Values("X-Client"); len(got) != 1 || got[0] != "2" {
+		t.Errorf("X-Client %q, want only the current client's", got)
+	}
+	if rec.Header().Get("Content-Type") != "text/html" {
+		t.Errorf("cached Content-Type %q", rec.Header().Get("Content-Type"))
+	}
+
+	// Changing the served headers must not change the cached entry
+	rec.Header().Set("Content-Type", "text/plain")
+	if rec = serveCached(handler, http.MethodGet, "/"); rec.Header().Get("Content-Type") != "text/html" {
+		t.Errorf("cached headers shared with a response, now %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestResponseCacheSizeCap(t *testing.T) {
+	calls := 0
+	cache := NewResponseCache(32)
+	handler := CacheMiddleware(cache)(countingHandler(&calls))
+
+	// A body larger than the whole cache is served but never stored, and
+	// does not evict what is already cached
+	serveCached(handler, http.MethodGet, "/a")
+	for i := 0; i < 2; i++ {
+		rec := serveCached(handler, http.MethodGet, "/large")
+		if rec.Code != http.StatusOK || rec.Body.Len() != 64 {
+			t.Fatalf("oversized response answered %d with %d bytes", rec.Code, rec.Body.Len())
+		}
+	}
+	if calls != 3 || cache.Len() != 1 {
+		t.Errorf("handler ran %d times with %d cached, want 3 and 1", calls, cache.Len())
+	}
+
+	for _, path := range []string{"/b", "/c", "/d", "/e", "/f", "/g"} {
+		serveCached(handler, http.MethodGet, path)
+		if cache.size > cache.maxBytes {
+			t.Fatalf("cache holds %d bytes, over the %d cap", cache.size, cache.maxBytes)
+		}
+	}
+
+	size := 0
+	for element := cache.order.Front(); element != nil; element = element.Next() {
+		size += len(element.Value.(*cachedResponse).body)
+	}
+	if size != cache.size {
+		t.Errorf("cache counts %d bytes but holds %d", cache.size, size)
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	entry := func(key string) *cachedResponse {
+		return &cachedResponse{key: key, body: []byte("0123456789")}
+	}
+	cache := NewResponseCache(30)
+
+	cache.put(entry("a"))
+	cache.put(entry("b"))
+	cache.put(entry("c"))
+	// Reading a makes b the least recently used
+	cache.get("a")
+	cache.put(entry("d"))
+
+	if cache.get("b") != nil {
+		t.Error("b survived, want it evicted as least recently used")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if cache.get(key) == nil {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+
+	// Replacing an entry refreshes it without counting its size twice
+	cache.put(entry("a"))
+	cache.put(entry("e"))
+	if cache.size != 30 || cache.Len() != 3 {
+		t.Errorf("cache holds %d entries of %d bytes, want 3 of 30", cache.Len(), cache.size)
+	}
+	if cache.get("c") != nil {
+		t.Error("c survived, want it evicted after a was replaced")
+	}
+
+	var order []string
+	for element := cache.order.Front(); element != nil; element = element.Next() {
+		order = append(order, element.Value.(*cachedResponse).key)
+	}
+	if strings.Join(order, "") != "ead" {
+		t.Errorf("recency order %q, want %q", order, "ead")
+	}
+}
+
+func TestResponseCacheInvalidateOnReload(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"templates", "html"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("templates/layout.html", `{{define "title"}}www{{end}}`)
+	writeFile("html/index.html", `{{template "title"}}`)
+	writeFile("CHANGELOG.md", "# Changelog\n\n## [0.1.0] - 2025-10-05\n\n### Added\n\n- First\n")
+	t.Chdir(dir)
+
+	registry := templates.NewRegistry(false, template.FuncMap{})
+	if err := registry.Register("index.html"); err != nil {
+		t.Fatal(err)
+	}
+	changelogStore := models.NewChangelogStore("CHANGELOG.md")
+
+	cache := NewResponseCache(1024)
+	registry.OnReload(cache.Invalidate)
+	changelogStore.OnReload(cache.Invalidate)
+
+	calls := 0
+	handler := CacheMiddleware(cache)(countingHandler(&calls))
+	fill := func() {
+		t.Helper()
+		serveCached(handler, http.MethodGet, "/")
+		serveCached(handler, http.MethodGet, "/changelog")
+		if rec := serveCached(handler, http.MethodGet, "/"); rec.Header().Get("X-Cache") != "HIT" || cache.Len() != 2 {
+			t.Fatalf("cache not filled, X-Cache %q with %d entries", rec.Header().Get("X-Cache"), cache.Len())
+		}
+	}
+
+	tests := []struct {
+		name   string
+		reload func() error
+	}{
+		{"templates", registry.Reload},
+		{"changelog", changelogStore.Load},
+	}
+
+	for _, tt := range tests {
+		fill()
+		if err := tt.reload(); err != nil {
+			t.Fatalf("%s reload: %v", tt.name, err)
+		}
+		if cache.Len() != 0 || cache.size != 0 {
+			t.Errorf("%s reload left %d entries of %d bytes", tt.name, cache.Len(), cache.size)
+		}
+		if rec := serveCached(handler, http.MethodGet, "/"); rec.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("after %s reload X-Cache %q, want MISS", tt.name, rec.Header().Get("X-Cache"))
+		}
+	}
+
+	// A failed changelog load keeps the previous data, and the cache with it
+	fill()
+	os.Remove(filepath.Join(dir, "CHANGELOG.md"))
+	if err := changelogStore.Load(); err == nil {
+		t.Fatal("loading a missing changelog succeeded")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("failed reload left %d entries, want the 2 cached", cache.Len())
+	}
+}
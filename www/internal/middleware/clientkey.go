@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// KeyFunc returns the identity a request is rate limited under
+type KeyFunc func(w http.ResponseWriter, r *http.Request) string
+
+// ClientIP resolves the address of the client that sent a request. Forwarding
+// headers are only believed when the connection comes from a trusted proxy,
+// otherwise any client could pick its own address.
+type ClientIP struct {
+	trustedProxies []netip.Prefix
+	ipv6Prefix     int
+}
+
+// NewClientIP parses the trusted proxy CIDRs or addresses. IPv6 clients are
+// grouped by their first ipv6Prefix bits, as one host usually owns a whole /64.
+func NewClientIP(trustedProxies []string, ipv6Prefix int) (*ClientIP, error) {
+	if ipv6Prefix < 1 || ipv6Prefix > 128 {
+		return nil, fmt.Errorf("ipv6 prefix %d must be between 1 and 128", ipv6Prefix)
+	}
+
+	prefixes, err := ParsePrefixes(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	return &ClientIP{trustedProxies: prefixes, ipv6Prefix: ipv6Prefix}, nil
+}
+
+// ParsePrefixes parses a list of CIDRs or single addresses
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, err
+			}
+			// Addresses are unmapped before they are matched, so an
+			// IPv4-mapped range must be too
+			if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+				prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Addr returns the client address. X-Forwarded-For is walked from the right,
+// skipping trusted proxies, so entries the client prepended are ignored.
+func (ci *ClientIP) Addr(r *http.Request) (netip.Addr, bool) {
+	addr, ok := parseAddr(r.RemoteAddr)
+	if !ok || !ci.trusted(addr) {
+		return addr, ok
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	if len(hops) == 0 {
+		if realIP, ok := parseAddr(r.Header.Get("X-Real-IP")); ok {
+			return realIP, true
+		}
+		return addr, true
+	}
+
+	for i := len(hops) - 1; i >= 0 && ci.trusted(addr); i-- {
+		hop, ok := parseAddr(hops[i])
+		if !ok {
+			break
+		}
+		addr = hop
+	}
+	return addr, true
+}
+
+// Key returns the client address as used in rate limit keys, with IPv6
+// addresses reduced to their prefix
+func (ci *ClientIP) Key(r *http.Request) string {
+	addr, ok := ci.Addr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+
+	if addr.Is6() && ci.ipv6Prefix < 128 {
+		prefix, err := addr.Prefix(ci.ipv6Prefix)
+		if err == nil {
+			return prefix.String()
+		}
+	}
+	return addr.String()
+}
+
+func (ci *ClientIP) trusted(addr netip.Addr) bool {
+	for _, prefix := range ci.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr accepts a bare address or host:port, as found in RemoteAddr
+func parseAddr(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// NewKeyFunc builds a KeyFunc from a "+" separated list of sources, "ip" for
+// the client address and "cookie" for the user_id cookie, e.g. "ip+cookie"
+func NewKeyFunc(sources string, clientIP *ClientIP) (KeyFunc, error) {
+	var parts []KeyFunc
+	for _, source := range strings.Split(sources, "+") {
+		switch strings.TrimSpace(source) {
+		case "ip":
+			parts = append(parts, func(w http.ResponseWriter, r *http.Request) string {
+				return clientIP.Key(r)
+			})
+		case "cookie":
+			parts = append(parts, func(w http.ResponseWriter, r *http.Request) string {
+				return getUserIDFromCookie(r, w)
+			})
+		default:
+			return nil, fmt.Errorf("unknown rate limit key source %q, expected ip or cookie", source)
+		}
+	}
+
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return func(w http.ResponseWriter, r *http.Request) string {
+		keys := make([]string, len(parts))
+		for i, part := range parts {
+			keys[i] = part(w, r)
+		}
+		return strings.Join(keys, "|")
+	}, nil
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    []string
+		wantErr bool
+	}{
+		{"empty", nil, []string{}, false},
+		{"addresses", []string{"10.0.0.1", " 2001:db8::1 "}, []string{"10.0.0.1/32", "2001:db8::1/128"}, false},
+		{"cidrs are masked", []string{"10.1.2.3/8", "2001:db8::1/32"}, []string{"10.0.0.0/8", "2001:db8::/32"}, false},
+		{"mapped address", []string{"::ffff:10.0.0.1"}, []string{"10.0.0.1/32"}, false},
+		{"mapped cidr", []string{"::ffff:10.0.0.0/104"}, []string{"10.0.0.0/8"}, false},
+		{"bad address", []string{"10.0.0.256"}, nil, true},
+		{"bad cidr", []string{"10.0.0.0/33"}, nil, true},
+		{"hostname", []string{"proxy.internal"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := ParsePrefixes(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := []string{}
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("prefixes %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPAddr(t *testing.T) {
+	clientIP, err := NewClientIP([]string{"10.0.0.0/8", "::1"}, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+		wantKey    string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:51234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted remote spoofing forwarded for",
+			remoteAddr: "203.0.113.7:51234",
+			forwarded:  []string{"198.51.100.1"},
+			realIP:     "198.51.100.2",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.1:443",
+			forwarded:  []string{"198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "trusted proxy chain",
+			remoteAddr: "10.0.0.1:443",
+			forwarded:  []string{"198.51.100.1, 10.0.0.3, 10.0.0.2"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "chain over several headers",
+			remoteAddr: "10.0.0.1:443",
+			forwarded:  []string{"198.51.100.1", "10.0.0.2"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client prepended hops",
+			remoteAddr: "10.0.0.1:443",
+			forwarded:  []string{"1.1.1.1, 10.0.0.9, 198.51.100.1, 10.0.0.2"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "unparseable hop stops at the nearest trusted proxy",
+			remoteAddr: "10.0.0.1:443",
+			forwarded:  []string{"198.51.100.1, not-an-ip, 10.0.0.2"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "hop with a port",
+			remoteAddr: "10.0.0.1:443",
+			forwarded:  []string{"198.51.100.1:8080"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.0.0.1:443",
+			forwarded:  []string{"10.0.0.3, 10.0.0.2"},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "real ip fallback",
+			remoteAddr: "10.0.0.1:443",
+			realIP:     "198.51.100.4",
+			want:       "198.51.100.4",
+		},
+		{
+			name:       "forwarded for wins over real ip",
+			remoteAddr: "10.0.0.1:443",
+			forwarded:  []string{"198.51.100.1"},
+			realIP:     "198.51.100.4",
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "unparseable real ip",
+			remoteAddr: "10.0.0.1:443",
+			realIP:     "unknown",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "mapped remote is trusted",
+			remoteAddr: "[::ffff:10.0.0.1]:443",
+			forwarded:  []string{"::ffff:198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "ipv6 proxy",
+			remoteAddr: "[::1]:443",
+			forwarded:  []string{"2001:db8:1:2:3:4:5:6"},
+			want:       "2001:db8:1:2:3:4:5:6",
+			wantKey:    "2001:db8:1:2::/64",
+		},
+		{
+			name:       "ipv6 client with zone",
+			remoteAddr: "[fe80::1%eth0]:443",
+			want:       "fe80::1",
+			wantKey:    "fe80::/64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			addr, ok := clientIP.Addr(r)
+			if !ok || addr != netip.MustParseAddr(tt.want) {
+				t.Errorf("Addr = %s, %v, want %s", addr, ok, tt.want)
+			}
+
+			wantKey := tt.wantKey
+			if wantKey == "" {
+				wantKey = tt.want
+			}
+			if key := clientIP.Key(r); key != wantKey {
+				t.Errorf("Key = %q, want %q", key, wantKey)
+			}
+		})
+	}
+}
+
+func TestClientIPKeyPrefix(t *testing.T) {
+	tests := []struct {
+		ipv6Prefix int
+		remoteAddr string
+		want       string
+	}{
+		{64, "[2001:db8:1:2:aaaa::1]:443", "2001:db8:1:2::/64"},
+		{64, "[2001:db8:1:2:bbbb::2]:443", "2001:db8:1:2::/64"},
+		{48, "[2001:db8:1:2::1]:443", "2001:db8:1::/48"},
+		{128, "[2001:db8:1:2::1]:443", "2001:db8:1:2::1"},
+		{64, "198.51.100.1:443", "198.51.100.1"},
+		{64, "[::ffff:198.51.100.1]:443", "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		clientIP, err := NewClientIP(nil, tt.ipv6Prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if key := clientIP.Key(r); key != tt.want {
+			t.Errorf("/%d %s: Key = %q, want %q", tt.ipv6Prefix, tt.remoteAddr, key, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "not an address"
+	clientIP, _ := NewClientIP(nil, 64)
+	if key := clientIP.Key(r); key != r.RemoteAddr {
+		t.Errorf("unparseable remote: Key = %q, want the raw RemoteAddr", key)
+	}
+
+	for _, prefix := range []int{0, 129} {
+		if _, err := NewClientIP(nil, prefix); err == nil {
+			t.Errorf("ipv6 prefix %d accepted", prefix)
+		}
+	}
+}
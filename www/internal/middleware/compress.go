@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encodings are the supported content codings, preferred in this order when
+// the client rates several of them equally
+var encodings = []string{"zstd", "br", "gzip"}
+
+// encoder is the part of the gzip, brotli and zstd writers the middleware uses
+type encoder interface {
+	io.Writer
+	Flush() error
+	Close() error
+	Reset(w io.Writer)
+}
+
+// encoderPools keep the encoders, whose buffers are expensive to allocate
+// per response
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	"zstd": {New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}},
+}
+
+// compressibleTypes are the non-text media types worth compressing. Images
+// other than SVG, fonts and archives are compressed already.
+var compressibleTypes = map[string]bool{
+	"application/json":       true,
+	"application/javascript": true,
+	"application/xml":        true,
+	"image/svg+xml":          true,
+}
+
+// CompressMiddleware compresses responses of at least minSize bytes with the
+// best coding the client accepts. It must wrap everything that buffers or
+// tags the body, such as the minifier and ConditionalMiddleware, so it sees
+// the final bytes.
+func CompressMiddleware(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := NegotiateEncoding(r.Header.Get("Accept-Encoding"), encodings...)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressResponseWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				minSize:        minSize,
+				ifNoneMatch:    r.Header.Get("If-None-Match"),
+			}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressResponseWriter holds back the first minSize bytes, then decides
+// whether the response is compressed and sends the headers
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	minSize     int
+	ifNoneMatch string
+	status      int
+	buffer      []byte
+	started     bool
+	encoder     encoder
+}
+
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.started || cw.status != 0 {
+		return
+	}
+	if status < http.StatusOK {
+		// Informational responses such as 103 Early Hints go out as they are
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+
+	switch {
+	case status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent:
+		cw.start(false)
+	case cw.shortContentLength():
+		cw.start(false)
+	}
+}
+
+func (cw *compressResponseWriter) Write(data []byte) (int, error) {
+	if !cw.started && cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.started {
+		cw.buffer = append(cw.buffer, data...)
+		if len(cw.buffer) >= cw.minSize {
+			cw.start(cw.compressible())
+		}
+		return len(data), nil
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(data)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+// start sends the headers and whatever was held back
+func (cw *compressResponseWriter) start(compress bool) {
+	cw.started = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	header := cw.Header()
+	// The body differs from the one the ETag was computed over, but means
+	// the same, which is what a weak ETag says. A 304 carries no body to
+	// decide on, so it is tagged the way the client shows the response it
+	// confirms was: weak only if the client holds the weak form.
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		if compress || cw.status == http.StatusNotModified && header.Get("Content-Encoding") == "" && holdsWeak(cw.ifNoneMatch, etag) {
+			header.Set("ETag", "W/"+etag)
+		}
+	}
+	if compress {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+
+		cw.encoder = encoderPools[cw.encoding].Get().(encoder)
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buffer) > 0 {
+		if cw.encoder != nil {
+			cw.encoder.Write(cw.buffer)
+		} else {
+			cw.ResponseWriter.Write(cw.buffer)
+		}
+	}
+	cw.buffer = nil
+}
+
+// compressible reports whether the response is worth compressing, sniffing
+// the Content-Type from the body if the handler did not set one
+func (cw *compressResponseWriter) compressible() bool {
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buffer)
+		header.Set("Content-Type", contentType)
+	}
+
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		compressibleTypes[mediaType]
+}
+
+// shortContentLength reports whether the handler announced a body too small
+// to compress
+func (cw *compressResponseWriter) shortContentLength() bool {
+	length, err := strconv.Atoi(cw.Header().Get("Content-Length"))
+	return err == nil && length < cw.minSize
+}
+
+// Flush sends what is held back, compressing it regardless of size since a
+// streamed response may grow past it
+func (cw *compressResponseWriter) Flush() {
+	if !cw.started {
+		cw.start(cw.compressible())
+	}
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close sends a body that stayed below minSize uncompressed, or finishes the
+// compressed stream
+func (cw *compressResponseWriter) Close() error {
+	if !cw.started {
+		if cw.status == 0 && len(cw.buffer) == 0 {
+			// The handler wrote nothing, let net/http send its default
+			return nil
+		}
+		cw.start(false)
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	cw.encoder.Reset(nil)
+	encoderPools[cw.encoding].Put(cw.encoder)
+	cw.encoder = nil
+	return err
+}
+
+// holdsWeak reports whether an If-None-Match header lists the weak form of
+// the strong etag, which this middleware sent on a compressed response
+func holdsWeak(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimSpace(candidate) == "W/"+etag {
+			return true
+		}
+	}
+	return false
+}
+
+// NegotiateEncoding returns the coding of encodings the Accept-Encoding header
+// rates highest, the earliest on a tie, or "" for an uncompressed response
+func NegotiateEncoding(acceptEncoding string, encodings ...string) string {
+	best, bestQuality := "", 0.0
+	for _, encoding := range encodings {
+		if quality := encodingQuality(acceptEncoding, encoding); quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// encodingQuality returns the q-value of encoding, or of "*" if the header
+// does not name it
+func encodingQuality(acceptEncoding, encoding string) float64 {
+	quality, named := 0.0, false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+
+		switch {
+		case coding == encoding:
+			quality, named = qValue(params), true
+		case coding == "*" && !named:
+			quality = qValue(params)
+		}
+	}
+	return quality
+}
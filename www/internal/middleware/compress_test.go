@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip, br", "br"},
+		{"GZIP", "gzip"},
+		{"gzip;q=1, br;q=0.5", "gzip"},
+		{"br;q=0.5, gzip;q=0.5", "br"},
+		{"gzip; q=0.8, zstd;q=0.9", "zstd"},
+		{"gzip;q=0", ""},
+		{"gzip;q=0, br", "br"},
+		{"identity", ""},
+		{"identity;q=0", ""},
+		{"identity;q=0, gzip", "gzip"},
+		{"*", "zstd"},
+		{"*;q=0", ""},
+		{"*;q=0.1, zstd;q=0", "br"},
+		{"zstd;q=0, *", "br"},
+		{"deflate, compress", ""},
+		{"gzip;q=invalid", "gzip"},
+	}
+
+	for _, tt := range tests {
+		if got := NegotiateEncoding(tt.acceptEncoding, encodings...); got != tt.want {
+			t.Errorf("NegotiateEncoding(%q) = %q, want %q", tt.acceptEncoding, got, tt.want)
+		}
+	}
+
+	if got := NegotiateEncoding("zstd, gzip", "br", "gzip"); got != "gzip" {
+		t.Errorf("NegotiateEncoding picked %q outside the offered codings", got)
+	}
+}
+
+const compressMinSize = 256
+
+var largeBody = strings.Repeat("<p>compress me</p>", 64)
+
+func TestCompressMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		contentType    string
+		contentLength  bool
+		encoding       string
+		body           string
+		wantEncoding   string
+	}{
+		{name: "large text", acceptEncoding: "gzip", contentType: "text/html", body: largeBody, wantEncoding: "gzip"},
+		{name: "sniffed type", acceptEncoding: "gzip", body: largeBody, wantEncoding: "gzip"},
+		{name: "json", acceptEncoding: "gzip", contentType: "application/feed+json", body: strings.Repeat(`{"a":1}`, 64), wantEncoding: "gzip"},
+		{name: "below min size", acceptEncoding: "gzip", contentType: "text/html", body: largeBody[:compressMinSize-1]},
+		{name: "at min size", acceptEncoding: "gzip", contentType: "text/html", body: largeBody[:compressMinSize], wantEncoding: "gzip"},
+		{name: "short content length", acceptEncoding: "gzip", contentType: "text/html", contentLength: true, body: largeBody[:100]},
+		{name: "not accepted", contentType: "text/html", body: largeBody},
+		{name: "identity only", acceptEncoding: "identity", contentType: "text/html", body: largeBody},
+		{name: "image", acceptEncoding: "gzip", contentType: "image/png", body: largeBody},
+		{name: "already encoded", acceptEncoding: "gzip", contentType: "text/css", encoding: "br", body: largeBody},
+		{name: "head", method: http.MethodHead, acceptEncoding: "gzip", contentType: "text/html", body: largeBody},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CompressMiddleware(compressMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				if tt.contentLength {
+					w.Header().Set("Content-Length", "100")
+				}
+				io.WriteString(w, tt.body)
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if !strings.Contains(strings.Join(rec.Header().Values("Vary"), ","), "Accept-Encoding") {
+				t.Errorf("Vary %q does not name Accept-Encoding", rec.Header().Values("Vary"))
+			}
+
+			gotEncoding := rec.Header().Get("Content-Encoding")
+			if tt.encoding != "" {
+				if gotEncoding != tt.encoding || rec.Body.String() != tt.body {
+					t.Errorf("pre-encoded response changed to %q", gotEncoding)
+				}
+				return
+			}
+			if gotEncoding != tt.wantEncoding {
+				t.Fatalf("Content-Encoding %q, want %q", gotEncoding, tt.wantEncoding)
+			}
+
+			body := rec.Body.Bytes()
+			if gotEncoding == "gzip" {
+				if rec.Header().Get("Content-Length") != "" {
+					t.Errorf("compressed response kept Content-Length %s", rec.Header().Get("Content-Length"))
+				}
+				reader, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(reader); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(body) != tt.body {
+				t.Errorf("body %d bytes, want the %d written", len(body), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressNoContent(t *testing.T) {
+	handler := CompressMiddleware(compressMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() != 0 {
+		t.Errorf("204 answered %d with encoding %q and %d bytes", rec.Code, rec.Header().Get("Content-Encoding"), rec.Body.Len())
+	}
+}
+
+// TestCompressETag checks that a 304 carries the same validator as the 200 it
+// confirms, whether or not that 200 was compressed
+func TestCompressETag(t *testing.T) {
+	modTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		weak    bool
+	}{
+		{
+			name: "compressed",
+			handler: ConditionalMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				io.WriteString(w, largeBody)
+			})),
+			weak: true,
+		},
+		{
+			name: "below min size",
+			handler: ConditionalMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				io.WriteString(w, "<p>short</p>")
+			})),
+		},
+		{
+			name: "not compressible",
+			handler: ConditionalMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				io.WriteString(w, largeBody)
+			})),
+		},
+		{
+			name: "precompressed",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var buffer bytes.Buffer
+				gz := gzip.NewWriter(&buffer)
+				io.WriteString(gz, largeBody)
+				gz.Close()
+
+				w.Header().Set("Content-Type", "text/css")
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Header().Set("ETag", `"asset-gzip"`)
+				http.ServeContent(w, r, "style.css", modTime, bytes.NewReader(buffer.Bytes()))
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CompressMiddleware(compressMinSize)(tt.handler)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			etag := rec.Header().Get("ETag")
+			if rec.Code != http.StatusOK || etag == "" {
+				t.Fatalf("200 answered %d with ETag %q", rec.Code, etag)
+			}
+			if weak := strings.HasPrefix(etag, "W/"); weak != tt.weak {
+				t.Errorf("200 ETag %s, want weak %v", etag, tt.weak)
+			}
+
+			r = httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			r.Header.Set("If-None-Match", etag)
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != http.StatusNotModified {
+				t.Fatalf("revalidation answered %d, want 304", rec.Code)
+			}
+			if got := rec.Header().Get("ETag"); got != etag {
+				t.Errorf("304 ETag %s, want the 200's %s", got, etag)
+			}
+			if rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() != 0 {
+				t.Errorf("304 has encoding %q and %d bytes", rec.Header().Get("Content-Encoding"), rec.Body.Len())
+			}
+		})
+	}
+}
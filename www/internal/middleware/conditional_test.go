@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConditionalMiddleware(t *testing.T) {
+	modTime := time.Date(2025, 3, 1, 12, 0, 0, 500, time.UTC)
+	body := "<p>page</p>"
+
+	handler := ConditionalMiddleware(func() time.Time { return modTime })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Length", "11")
+		w.Header().Set("Cache-Control", "no-cache")
+		io.WriteString(w, body)
+	}))
+
+	// The ETag of the body, taken from an unconditional request
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Fatalf("unconditional request answered %d %q", rec.Code, rec.Body.String())
+	}
+	if len(etag) < 3 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		t.Fatalf("ETag %q is not a strong entity tag", etag)
+	}
+	sentModified := rec.Header().Get("Last-Modified")
+	if sentModified != "Sat, 01 Mar 2025 12:00:00 GMT" {
+		t.Errorf("Last-Modified %q, want the mod time truncated to seconds", sentModified)
+	}
+
+	lastModified := modTime.Format(http.TimeFormat)
+	earlier := modTime.Add(-time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		ifModified  string
+		want        int
+	}{
+		{name: "matching etag", ifNoneMatch: etag, want: http.StatusNotModified},
+		{name: "other etag", ifNoneMatch: `"other"`, want: http.StatusOK},
+		{name: "etag in a list", ifNoneMatch: `"a", ` + etag + `,"b"`, want: http.StatusNotModified},
+		{name: "etag missing from a list", ifNoneMatch: `"a", "b"`, want: http.StatusOK},
+		{name: "weak comparison", ifNoneMatch: "W/" + etag, want: http.StatusNotModified},
+		{name: "any", ifNoneMatch: "*", want: http.StatusNotModified},
+		{name: "unmodified since", ifModified: lastModified, want: http.StatusNotModified},
+		{name: "unmodified since later", ifModified: modTime.Add(time.Hour).Format(http.TimeFormat), want: http.StatusNotModified},
+		{name: "modified since", ifModified: earlier, want: http.StatusOK},
+		{name: "invalid date", ifModified: "yesterday", want: http.StatusOK},
+		{name: "etag wins over date", ifNoneMatch: `"other"`, ifModified: lastModified, want: http.StatusOK},
+		{name: "etag wins over old date", ifNoneMatch: etag, ifModified: earlier, want: http.StatusNotModified},
+		{name: "head", method: http.MethodHead, ifNoneMatch: etag, want: http.StatusNotModified},
+		{name: "post", method: http.MethodPost, ifNoneMatch: etag, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifModified != "" {
+				r.Header.Set("If-Modified-Since", tt.ifModified)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Fatalf("answered %d, want %d", rec.Code, tt.want)
+			}
+
+			header := rec.Header()
+			if tt.want == http.StatusNotModified {
+				if rec.Body.Len() != 0 {
+					t.Errorf("304 has a %d byte body", rec.Body.Len())
+				}
+				if header.Get("Content-Type") != "" || header.Get("Content-Length") != "" {
+					t.Errorf("304 kept Content-Type %q and Content-Length %q", header.Get("Content-Type"), header.Get("Content-Length"))
+				}
+				if header.Get("ETag") != etag || header.Get("Last-Modified") != sentModified || header.Get("Cache-Control") != "no-cache" {
+					t.Errorf("304 headers %v, want the validators and caching headers kept", header)
+				}
+				return
+			}
+
+			if method == http.MethodPost {
+				if header.Get("ETag") != "" {
+					t.Errorf("POST response tagged %s", header.Get("ETag"))
+				}
+			} else if header.Get("ETag") != etag {
+				t.Errorf("ETag %q, want %q", header.Get("ETag"), etag)
+			}
+			if method != http.MethodHead && rec.Body.String() != body {
+				t.Errorf("body %q, want %q", rec.Body.String(), body)
+			}
+		})
+	}
+}
+
+func TestConditionalMiddlewareErrors(t *testing.T) {
+	handler := ConditionalMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "missing", http.StatusNotFound)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", "*")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "missing\n" {
+		t.Errorf("error answered %d %q, want it passed through", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("ETag") != "" || rec.Header().Get("Last-Modified") != "" {
+		t.Errorf("error response tagged with %v", rec.Header())
+	}
+}
+
+func TestConditionalMiddlewareWithoutModTime(t *testing.T) {
+	for _, lastModified := range []func() time.Time{nil, func() time.Time { return time.Time{} }} {
+		handler := ConditionalMiddleware(lastModified)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "body")
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("If-Modified-Since", time.Now().Format(http.TimeFormat))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+
+		if rec.Code != http.StatusOK || rec.Header().Get("Last-Modified") != "" {
+			t.Errorf("without a mod time answered %d with Last-Modified %q", rec.Code, rec.Header().Get("Last-Modified"))
+		}
+	}
+}
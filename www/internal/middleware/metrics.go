@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/0x800a6/www/internal/metrics"
+)
+
+// MetricsMiddleware counts requests and their latency by the ServeMux pattern
+// that handled them, keeping the number of series bounded
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		sw := &statusResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		// ServeMux records the matched pattern on the request it was given,
+		// requests refused before routing have none
+		route := r.Pattern
+		if route == "" {
+			route = "none"
+		}
+		status := strconv.Itoa(sw.Status())
+
+		metrics.HTTPRequests.Inc(route, methodLabel(r.Method), status)
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), route, status)
+	})
+}
+
+// standardMethods are the methods counted under their own name
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// methodLabel folds the methods clients make up into "other", since any
+// token is a valid method and each would add a series
+func methodLabel(method string) string {
+	if standardMethods[method] {
+		return method
+	}
+	return "other"
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 
+	"github.com/0x800a6/www/internal/metrics"
 	"github.com/tdewolff/minify/v2"
 	"github.com/tdewolff/minify/v2/html"
 )
@@ -32,6 +33,7 @@ func (mrw *MinifyResponseWriter) Write(data []byte) (int, error) {
 func (mrw *MinifyResponseWriter) Flush() error {
 	minified, err := mrw.minify.Bytes("text/html", mrw.buffer.Bytes())
 	if err != nil {
+		metrics.MinifyFailures.Inc()
 		_, writeErr := mrw.ResponseWriter.Write(mrw.buffer.Bytes())
 		return writeErr
 	}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+)
+
+// negotiate returns the offer the Accept header rates highest, the earliest
+// offer on a tie or when nothing is acceptable
+func negotiate(accept string, offers ...string) string {
+	best, bestQuality := offers[0], 0.0
+	for _, offer := range offers {
+		if quality := acceptQuality(accept, offer); quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the q-value of the most specific media range in
+// accept that matches offer
+func acceptQuality(accept, offer string) float64 {
+	if strings.TrimSpace(accept) == "" {
+		return 1
+	}
+
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(part, ";")
+		mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+
+		var s int
+		switch {
+		case mediaRange == offer:
+			s = 2
+		case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*")):
+			s = 1
+		case mediaRange == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+
+		q := qValue(params)
+		if s > specificity {
+			specificity, quality = s, q
+		} else {
+			quality = max(quality, q)
+		}
+	}
+	return quality
+}
+
+// qValue returns the q parameter of a header element, 1 if it has none
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(name, "q") {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				return parsed
+			}
+		}
+	}
+	return 1
+}
@@ -1,42 +1,88 @@
 package middleware
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"net/netip"
+	"path"
 	"strings"
 	"time"
 
+	"github.com/0x800a6/www/internal/metrics"
 	"github.com/0x800a6/www/internal/models"
 )
 
-func RateLimitMiddleware(limiter *models.RateLimiter) func(http.Handler) http.Handler {
+// RateLimitRoute assigns the paths matching any of Paths to a policy. A
+// pattern ending in "/" matches the whole subtree, one containing "*" is
+// matched with path.Match, and anything else must match exactly.
+type RateLimitRoute struct {
+	Policy string
+	Paths  []string
+}
+
+// RateLimitRules decide how RateLimitMiddleware treats each request. Denied
+// clients are refused everywhere, allowed clients and exempt paths are never
+// limited, and every other request is limited under the policy of the first
+// matching route, or models.DefaultPolicy.
+type RateLimitRules struct {
+	Key             KeyFunc
+	ClientIP        *ClientIP
+	Routes          []RateLimitRoute
+	ExemptPaths     []string
+	AllowIPs        []netip.Prefix
+	AllowUserAgents []string
+	DenyIPs         []netip.Prefix
+	DenyUserAgents  []string
+	// Escalator, if set, bans keys that keep getting refused
+	Escalator *models.Escalator
+	// Page renders the rate limit page for browsers that were refused
+	Page http.Handler
+}
+
+// RateLimitMiddleware limits requests per identity returned by rules.Key
+func RateLimitMiddleware(limiter models.Limiter, rules *RateLimitRules) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if shouldSkipRateLimit(r.URL.Path) {
-				next.ServeHTTP(w, r)
+			if rules.denied(r) {
+				metrics.RateLimitDenied.Inc()
+				http.Error(w, "Forbidden", http.StatusForbidden)
 				return
 			}
 
-			userID := getUserIDFromCookie(r, w)
+			if rules.exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-			if !limiter.Allow(userID) {
-				w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limiter.GetConfig().BurstSize))
-				w.Header().Set("X-RateLimit-Remaining", "0")
-				w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(limiter.GetConfig().WindowSize).Unix()))
-				w.Header().Set("Retry-After", fmt.Sprintf("%.0f", limiter.GetConfig().WindowSize.Seconds()))
+			policy := rules.policy(r.URL.Path)
+			clientKey := rules.Key(w, r)
 
-				http.Redirect(w, r, "/ratelimit", http.StatusTooManyRequests)
+			// A backend outage must not take the site down with it
+			result, err := limiter.Allow(policy, clientKey)
+			if err != nil {
+				log.Printf("ratelimit: %v", err)
+				next.ServeHTTP(w, r)
 				return
 			}
 
-			bucket := limiter.GetBucket(userID)
-			if bucket != nil {
-				remaining := bucket.GetTokens()
-				w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limiter.GetConfig().BurstSize))
-				w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-				w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(limiter.GetConfig().WindowSize).Unix()))
+			if !result.Allowed && !result.Banned {
+				result = rules.escalate(clientKey, result)
+			}
+
+			setAccessLogRateLimit(r, clientKey, result.Remaining)
+			setRateLimitHeaders(w, policy, result)
+
+			if !result.Allowed {
+				metrics.RateLimitRejections.Inc(policy)
+				r = r.WithContext(context.WithValue(r.Context(), rateLimitKey{}, clientKey))
+				rules.reject(w, r, policy, result)
+				return
 			}
 
 			next.ServeHTTP(w, r)
@@ -44,26 +90,168 @@ func RateLimitMiddleware(limiter *models.RateLimiter) func(http.Handler) http.Ha
 	}
 }
 
-func shouldSkipRateLimit(path string) bool {
-	if strings.HasPrefix(path, "/static/") {
-		return true
+// escalate counts a rejection against the key, turning the result into a
+// ban once the key has been refused too often
+func (rules *RateLimitRules) escalate(clientKey string, result models.RateLimitResult) models.RateLimitResult {
+	if rules.Escalator == nil {
+		return result
+	}
+
+	penalty, banned, err := rules.Escalator.Strike(clientKey)
+	if err != nil {
+		log.Printf("ratelimit: escalating %q: %v", clientKey, err)
 	}
+	if !banned {
+		return result
+	}
+
+	metrics.RateLimitPenalties.Inc()
+	log.Printf("ratelimit: banned %q for %s after repeated rejections (level %d)", clientKey, time.Until(penalty.Until).Round(time.Second), penalty.Level)
+
+	result.Banned = true
+	result.Remaining = 0
+	result.ResetAt = penalty.Until
+	result.RetryAfter = time.Until(penalty.Until)
+	return result
+}
+
+type rateLimitKey struct{}
+
+// RequestKey returns the key a refused request was limited under. Requests the
+// middleware did not refuse, such as visits to the exempt rate limit page,
+// get the key they would have been limited under. A client without a
+// user_id cookie has no cookie based key yet, and is not sent one from here.
+func (rules *RateLimitRules) RequestKey(r *http.Request) string {
+	if key, ok := r.Context().Value(rateLimitKey{}).(string); ok {
+		return key
+	}
+	return rules.Key(&discardResponseWriter{header: make(http.Header)}, r)
+}
 
-	if path == "/health" || path == "/metrics" {
-		return true
+// setRateLimitHeaders sets both the X-RateLimit-* headers and the IETF
+// RateLimit and RateLimit-Policy fields
+func setRateLimitHeaders(w http.ResponseWriter, policy string, result models.RateLimitResult) {
+	reset := max(int(math.Ceil(time.Until(result.ResetAt).Seconds())), 0)
+	window := max(int(math.Ceil(result.Window.Seconds())), 1)
+
+	w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", result.ResetAt.Unix()))
+	w.Header().Set("RateLimit-Policy", fmt.Sprintf("%q;q=%d;w=%d", policy, result.Limit, window))
+	w.Header().Set("RateLimit", fmt.Sprintf("%q;r=%d;t=%d", policy, result.Remaining, reset))
+}
+
+// problem is an RFC 9457 problem details body
+type problem struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail"`
+	Instance   string `json:"instance"`
+	Policy     string `json:"policy"`
+	RetryAfter int    `json:"retry_after"`
+	RequestID  string `json:"request_id,omitempty"`
+}
+
+// reject answers a refused request in the format the client asked for: the
+// rate limit page for browsers, problem details for JSON clients and plain
+// text for everything else
+func (rules *RateLimitRules) reject(w http.ResponseWriter, r *http.Request, policy string, result models.RateLimitResult) {
+	retryAfter := max(int(math.Ceil(result.RetryAfter.Seconds())), 1)
+	detail := fmt.Sprintf("Too many requests, retry in %d seconds.", retryAfter)
+
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch negotiate(r.Header.Get("Accept"), "text/plain", "text/html", "application/problem+json", "application/json") {
+	case "text/html":
+		if rules.Page != nil {
+			rules.Page.ServeHTTP(w, r)
+			return
+		}
+	case "application/problem+json", "application/json":
+		body, err := json.MarshalIndent(problem{
+			Type:       "about:blank",
+			Title:      http.StatusText(http.StatusTooManyRequests),
+			Status:     http.StatusTooManyRequests,
+			Detail:     detail,
+			Instance:   r.URL.Path,
+			Policy:     policy,
+			RetryAfter: retryAfter,
+			RequestID:  RequestID(r),
+		}, "", "  ")
+		if err == nil {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(append(body, '\n'))
+			return
+		}
 	}
 
-	if path == "/ratelimit" {
-		return true
+	http.Error(w, detail, http.StatusTooManyRequests)
+}
+
+func (rules *RateLimitRules) denied(r *http.Request) bool {
+	return rules.matchesClient(r, rules.DenyIPs, rules.DenyUserAgents)
+}
+
+func (rules *RateLimitRules) exempt(r *http.Request) bool {
+	for _, pattern := range rules.ExemptPaths {
+		if MatchPath(pattern, r.URL.Path) {
+			return true
+		}
 	}
+	return rules.matchesClient(r, rules.AllowIPs, rules.AllowUserAgents)
+}
 
-	if path == "/favicon.ico" || path == "/robots.txt" {
-		return true
+// matchesClient reports whether the client address is in one of prefixes or
+// its User-Agent contains one of userAgents, ignoring case
+func (rules *RateLimitRules) matchesClient(r *http.Request, prefixes []netip.Prefix, userAgents []string) bool {
+	if len(prefixes) > 0 {
+		if addr, ok := rules.ClientIP.Addr(r); ok {
+			for _, prefix := range prefixes {
+				if prefix.Contains(addr) {
+					return true
+				}
+			}
+		}
 	}
 
+	userAgent := strings.ToLower(r.UserAgent())
+	if userAgent == "" {
+		return false
+	}
+	for _, agent := range userAgents {
+		if strings.Contains(userAgent, strings.ToLower(agent)) {
+			return true
+		}
+	}
 	return false
 }
 
+func (rules *RateLimitRules) policy(urlPath string) string {
+	for _, route := range rules.Routes {
+		for _, pattern := range route.Paths {
+			if MatchPath(pattern, urlPath) {
+				return route.Policy
+			}
+		}
+	}
+	return models.DefaultPolicy
+}
+
+// MatchPath reports whether urlPath matches a RateLimitRoute pattern
+func MatchPath(pattern, urlPath string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(urlPath, pattern)
+	}
+	if strings.Contains(pattern, "*") {
+		matched, _ := path.Match(pattern, urlPath)
+		return matched
+	}
+	return pattern == urlPath
+}
+
 func getUserIDFromCookie(r *http.Request, w http.ResponseWriter) string {
 	cookie, err := r.Cookie("user_id")
 	if err == nil && cookie.Value != "" {
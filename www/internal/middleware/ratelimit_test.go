@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0x800a6/www/internal/models"
+)
+
+// TestRequestKeyFindsPenalty checks that a client banned by escalation is
+// shown its penalty on the exempt rate limit page, whatever the key is made of
+func TestRequestKeyFindsPenalty(t *testing.T) {
+	clientIP, err := NewClientIP(nil, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sources := range []string{"ip", "cookie", "ip+cookie"} {
+		t.Run(sources, func(t *testing.T) {
+			key, err := NewKeyFunc(sources, clientIP)
+			if err != nil {
+				t.Fatal(err)
+			}
+			limiter := models.NewRateLimiter(models.RateLimiterConfig{
+				Policies: map[string]models.RateLimitPolicy{
+					models.DefaultPolicy: {Algorithm: models.FixedWindow, RequestsPerMinute: 60, BurstSize: 1, WindowSize: time.Minute},
+				},
+				CleanupInterval: time.Hour,
+			})
+			defer limiter.Stop()
+			escalator := models.NewEscalator(limiter, models.EscalationConfig{Strikes: 2, Period: time.Minute, Penalty: time.Minute, MaxPenalty: time.Hour, Forget: time.Hour})
+			defer escalator.Stop()
+
+			rules := &RateLimitRules{Key: key, ClientIP: clientIP, ExemptPaths: []string{"/ratelimit"}, Escalator: escalator}
+			var pageKey string
+			handler := RateLimitMiddleware(limiter, rules)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pageKey = rules.RequestKey(r)
+			}))
+
+			request := func(path string) *http.Request {
+				r := httptest.NewRequest(http.MethodGet, path, nil)
+				r.RemoteAddr = "203.0.113.7:51234"
+				r.AddCookie(&http.Cookie{Name: "user_id", Value: "0123456789abcdef"})
+				return r
+			}
+
+			var last *httptest.ResponseRecorder
+			for i := 0; i < 3; i++ {
+				last = httptest.NewRecorder()
+				handler.ServeHTTP(last, request("/"))
+			}
+			if last.Code != http.StatusTooManyRequests {
+				t.Fatalf("third request answered %d, want 429", last.Code)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, request("/ratelimit"))
+			if _, ok := escalator.Penalty(pageKey); !ok {
+				t.Errorf("no penalty found for the page key %q", pageKey)
+			}
+			if rec.Header().Get("Set-Cookie") != "" {
+				t.Errorf("rate limit page set %q", rec.Header().Get("Set-Cookie"))
+			}
+
+			// A client without the cookie is not the banned one, unless only
+			// the address counts
+			r := httptest.NewRequest(http.MethodGet, "/ratelimit", nil)
+			r.RemoteAddr = "203.0.113.7:51234"
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			if _, ok := escalator.Penalty(pageKey); ok != (sources == "ip") {
+				t.Errorf("cookieless client with key %q penalized %v", pageKey, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import "net/http"
+
+// statusResponseWriter records the status and the bytes that actually reach
+// the client, after minification and compression
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sw *statusResponseWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusResponseWriter) Write(data []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(data)
+	sw.bytes += n
+	return n, err
+}
+
+func (sw *statusResponseWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sw *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Status returns the status sent, which is 200 if the handler never wrote
+func (sw *statusResponseWriter) Status() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
+// discardResponseWriter drops everything written to it, for running a KeyFunc
+// outside of a response
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (dw *discardResponseWriter) Header() http.Header {
+	return dw.header
+}
+
+func (dw *discardResponseWriter) WriteHeader(status int) {}
+
+func (dw *discardResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
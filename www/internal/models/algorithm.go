@@ -0,0 +1,206 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Algorithm selects how a Limiter spends and restores a key's budget
+type Algorithm string
+
+const (
+	// FixedWindow allows BurstSize requests per WindowSize, restoring the
+	// whole budget when the window ends
+	FixedWindow Algorithm = "fixed_window"
+	// TokenBucket refills RequestsPerMinute tokens per minute continuously
+	// into a bucket holding at most BurstSize
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindowLog allows BurstSize requests in any WindowSize span
+	SlidingWindowLog Algorithm = "sliding_window_log"
+	// GCRA is the generic cell rate algorithm, equivalent to TokenBucket
+	// but tracking a single timestamp per key
+	GCRA Algorithm = "gcra"
+)
+
+// ParseAlgorithm validates an algorithm name from configuration
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch algorithm := Algorithm(name); algorithm {
+	case FixedWindow, TokenBucket, SlidingWindowLog, GCRA:
+		return algorithm, nil
+	}
+	return "", fmt.Errorf("unknown rate limit algorithm %q", name)
+}
+
+// limitState is everything any algorithm remembers about one key. Only the
+// fields of the configured algorithm are used.
+type limitState struct {
+	// FixedWindow
+	WindowStart time.Time
+	Count       int
+	// TokenBucket
+	Tokens  float64
+	Updated time.Time
+	// SlidingWindowLog
+	Log []time.Time
+	// GCRA theoretical arrival time
+	TAT time.Time
+}
+
+// apply runs the algorithm for one request at now, consuming budget only if
+// consume is set, and reports the resulting state of the key
+func (a Algorithm) apply(state *limitState, policy RateLimitPolicy, now time.Time, consume bool) RateLimitResult {
+	var result RateLimitResult
+	switch a {
+	case TokenBucket:
+		result = state.tokenBucket(policy, now, consume)
+	case SlidingWindowLog:
+		result = state.slidingWindowLog(policy, now, consume)
+	case GCRA:
+		result = state.gcra(policy, now, consume)
+	default:
+		result = state.fixedWindow(policy, now, consume)
+	}
+	result.Window = policy.window()
+	return result
+}
+
+// window is the period in which the policy restores a full budget
+func (p RateLimitPolicy) window() time.Duration {
+	if p.Algorithm == TokenBucket || p.Algorithm == GCRA {
+		return perToken(p) * time.Duration(p.BurstSize)
+	}
+	return p.WindowSize
+}
+
+// lastSeen is when the key last changed, used to expire idle keys
+func (s *limitState) lastSeen() time.Time {
+	latest := s.WindowStart
+	for _, t := range []time.Time{s.Updated, s.TAT} {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	if len(s.Log) > 0 && s.Log[len(s.Log)-1].After(latest) {
+		latest = s.Log[len(s.Log)-1]
+	}
+	return latest
+}
+
+func (s *limitState) fixedWindow(policy RateLimitPolicy, now time.Time, consume bool) RateLimitResult {
+	if s.WindowStart.IsZero() || now.Sub(s.WindowStart) >= policy.WindowSize {
+		s.WindowStart = now
+		s.Count = 0
+	}
+
+	allowed := s.Count < policy.BurstSize
+	if allowed && consume {
+		s.Count++
+	}
+
+	resetAt := s.WindowStart.Add(policy.WindowSize)
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     policy.BurstSize,
+		Remaining: max(policy.BurstSize-s.Count, 0),
+		ResetAt:   resetAt,
+	}
+	if !allowed {
+		result.RetryAfter = resetAt.Sub(now)
+	}
+	return result
+}
+
+// perToken is the time it takes to earn one request back
+func perToken(policy RateLimitPolicy) time.Duration {
+	return time.Minute / time.Duration(policy.RequestsPerMinute)
+}
+
+func (s *limitState) tokenBucket(policy RateLimitPolicy, now time.Time, consume bool) RateLimitResult {
+	capacity := float64(policy.BurstSize)
+	interval := perToken(policy)
+
+	if s.Updated.IsZero() {
+		s.Tokens = capacity
+	} else if elapsed := now.Sub(s.Updated); elapsed > 0 {
+		s.Tokens = math.Min(capacity, s.Tokens+float64(elapsed)/float64(interval))
+	}
+	s.Updated = now
+
+	allowed := s.Tokens >= 1
+	if allowed && consume {
+		s.Tokens--
+	}
+
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     policy.BurstSize,
+		Remaining: int(s.Tokens),
+		ResetAt:   now.Add(time.Duration((capacity - s.Tokens) * float64(interval))),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - s.Tokens) * float64(interval))
+	}
+	return result
+}
+
+func (s *limitState) slidingWindowLog(policy RateLimitPolicy, now time.Time, consume bool) RateLimitResult {
+	cutoff := now.Add(-policy.WindowSize)
+	kept := s.Log[:0]
+	for _, t := range s.Log {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	s.Log = kept
+
+	allowed := len(s.Log) < policy.BurstSize
+	if allowed && consume {
+		s.Log = append(s.Log, now)
+	}
+
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     policy.BurstSize,
+		Remaining: max(policy.BurstSize-len(s.Log), 0),
+		ResetAt:   now,
+	}
+	if len(s.Log) > 0 {
+		// The whole budget is back once the newest request leaves the window
+		result.ResetAt = s.Log[len(s.Log)-1].Add(policy.WindowSize)
+	}
+	if !allowed {
+		result.RetryAfter = s.Log[0].Add(policy.WindowSize).Sub(now)
+	}
+	return result
+}
+
+func (s *limitState) gcra(policy RateLimitPolicy, now time.Time, consume bool) RateLimitResult {
+	interval := perToken(policy)
+	burst := interval * time.Duration(policy.BurstSize)
+
+	tat := s.TAT
+	if tat.Before(now) {
+		tat = now
+	}
+
+	// A request is allowed while the theoretical arrival time stays within
+	// one burst of now
+	allowAt := tat.Add(interval).Add(-burst)
+	allowed := !now.Before(allowAt)
+	if allowed && consume {
+		tat = tat.Add(interval)
+		s.TAT = tat
+	}
+
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     policy.BurstSize,
+		Remaining: max(int((burst-tat.Sub(now))/interval), 0),
+		ResetAt:   tat,
+	}
+	if !allowed {
+		result.RetryAfter = allowAt.Sub(now)
+	}
+	return result
+}
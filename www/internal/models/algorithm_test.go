@@ -0,0 +1,145 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// algorithmStep is one request at start+at, and the result it must get
+type algorithmStep struct {
+	at         time.Duration
+	consume    bool
+	allowed    bool
+	remaining  int
+	resetAt    time.Duration
+	retryAfter time.Duration
+}
+
+func TestAlgorithms(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := time.Second
+
+	// One token per second, three at most, and a ten second window
+	tests := []struct {
+		algorithm Algorithm
+		window    time.Duration
+		steps     []algorithmStep
+	}{
+		{
+			algorithm: TokenBucket,
+			window:    3 * second,
+			steps: []algorithmStep{
+				{0, false, true, 3, 0, 0},
+				{0, true, true, 2, 1 * second, 0},
+				{0, true, true, 1, 2 * second, 0},
+				{0, true, true, 0, 3 * second, 0},
+				{0, true, false, 0, 3 * second, 1 * second},
+				{500 * time.Millisecond, true, false, 0, 3 * second, 500 * time.Millisecond},
+				{1 * second, true, true, 0, 4 * second, 0},
+				{2500 * time.Millisecond, false, true, 1, 4 * second, 0},
+				// The bucket never holds more than the burst
+				{time.Hour, false, true, 3, time.Hour, 0},
+			},
+		},
+		{
+			algorithm: GCRA,
+			window:    3 * second,
+			steps: []algorithmStep{
+				{0, false, true, 3, 0, 0},
+				{0, true, true, 2, 1 * second, 0},
+				{0, true, true, 1, 2 * second, 0},
+				{0, true, true, 0, 3 * second, 0},
+				{0, true, false, 0, 3 * second, 1 * second},
+				{500 * time.Millisecond, true, false, 0, 3 * second, 500 * time.Millisecond},
+				{1 * second, true, true, 0, 4 * second, 0},
+				{2500 * time.Millisecond, false, true, 1, 4 * second, 0},
+				{time.Hour, false, true, 3, time.Hour, 0},
+			},
+		},
+		{
+			algorithm: FixedWindow,
+			window:    10 * second,
+			steps: []algorithmStep{
+				{0, true, true, 2, 10 * second, 0},
+				{2 * second, true, true, 1, 10 * second, 0},
+				{4 * second, false, true, 1, 10 * second, 0},
+				{4 * second, true, true, 0, 10 * second, 0},
+				{4 * second, true, false, 0, 10 * second, 6 * second},
+				{9999 * time.Millisecond, true, false, 0, 10 * second, time.Millisecond},
+				// A new window starts with the request that finds the old one over
+				{10 * second, true, true, 2, 20 * second, 0},
+				{25 * second, false, true, 3, 35 * second, 0},
+			},
+		},
+		{
+			algorithm: SlidingWindowLog,
+			window:    10 * second,
+			steps: []algorithmStep{
+				{0, false, true, 3, 0, 0},
+				{0, true, true, 2, 10 * second, 0},
+				{2 * second, true, true, 1, 12 * second, 0},
+				{4 * second, true, true, 0, 14 * second, 0},
+				// Refused until the oldest request leaves the window
+				{5 * second, true, false, 0, 14 * second, 5 * second},
+				{10 * second, true, true, 0, 20 * second, 0},
+				{10 * second, false, false, 0, 20 * second, 2 * second},
+				{13 * second, false, true, 1, 20 * second, 0},
+				{30 * second, false, true, 3, 30 * second, 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.algorithm), func(t *testing.T) {
+			policy := RateLimitPolicy{Algorithm: tt.algorithm, RequestsPerMinute: 60, BurstSize: 3, WindowSize: 10 * time.Second}
+			state := &limitState{}
+
+			for i, step := range tt.steps {
+				result := tt.algorithm.apply(state, policy, start.Add(step.at), step.consume)
+
+				if result.Allowed != step.allowed || result.Remaining != step.remaining {
+					t.Errorf("step %d at %s: allowed %v with %d remaining, want %v with %d",
+						i, step.at, result.Allowed, result.Remaining, step.allowed, step.remaining)
+				}
+				if want := start.Add(step.resetAt); !result.ResetAt.Equal(want) {
+					t.Errorf("step %d at %s: reset at %s, want %s", i, step.at, result.ResetAt.Sub(start), step.resetAt)
+				}
+				if result.RetryAfter != step.retryAfter {
+					t.Errorf("step %d at %s: retry after %s, want %s", i, step.at, result.RetryAfter, step.retryAfter)
+				}
+				if result.Limit != 3 || result.Window != tt.window {
+					t.Errorf("step %d: limit %d window %s, want 3 and %s", i, result.Limit, result.Window, tt.window)
+				}
+			}
+		})
+	}
+}
+
+func TestPeekDoesNotConsume(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, algorithm := range []Algorithm{FixedWindow, TokenBucket, SlidingWindowLog, GCRA} {
+		policy := RateLimitPolicy{Algorithm: algorithm, RequestsPerMinute: 60, BurstSize: 3, WindowSize: 10 * time.Second}
+		state := &limitState{}
+		algorithm.apply(state, policy, now, true)
+
+		for range 5 {
+			if result := algorithm.apply(state, policy, now, false); result.Remaining != 2 {
+				t.Errorf("%s: peek left %d remaining, want 2", algorithm, result.Remaining)
+			}
+		}
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for _, name := range []string{"fixed_window", "token_bucket", "sliding_window_log", "gcra"} {
+		if algorithm, err := ParseAlgorithm(name); err != nil || string(algorithm) != name {
+			t.Errorf("ParseAlgorithm(%q) = %q, %v", name, algorithm, err)
+		}
+	}
+	for _, name := range []string{"", "GCRA", "leaky_bucket"} {
+		if _, err := ParseAlgorithm(name); err == nil {
+			t.Errorf("ParseAlgorithm(%q) succeeded", name)
+		}
+	}
+}
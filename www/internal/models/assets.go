@@ -0,0 +1,299 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/svg"
+)
+
+// assetHashLength is the number of hex digits of the content hash put in
+// fingerprinted names
+const assetHashLength = 10
+
+// retiredAssetLifetime is how long fingerprinted names of a previous Load keep
+// being served, well past the hour pages may be kept by shared caches
+// (s-maxage=3600) while still pointing at them
+const retiredAssetLifetime = 24 * time.Hour
+
+// Asset is a static file as served, minified and with its precompressed
+// variants
+type Asset struct {
+	Name        string
+	ContentType string
+	Content     []byte
+	// Encoded holds the precompressed bodies by content coding, only for
+	// codings that make the file meaningfully smaller
+	Encoded map[string][]byte
+	Hash    string
+	ModTime time.Time
+	// Fingerprinted is set on the copy served under the hashed name, whose
+	// content can never change
+	Fingerprinted bool
+}
+
+// AssetStore runs the static/ files through the asset pipeline once per Load
+// and keeps the results in memory. Fingerprinted names replaced by a later
+// Load are retired rather than dropped, so cached pages keep working.
+type AssetStore struct {
+	dir    string
+	assets map[string]*Asset
+	urls   map[string]string
+	// retired holds the expiry of fingerprinted names from previous loads
+	retired map[string]time.Time
+	mutex   sync.RWMutex
+}
+
+func NewAssetStore(dir string) *AssetStore {
+	return &AssetStore{
+		dir:     dir,
+		assets:  make(map[string]*Asset),
+		urls:    make(map[string]string),
+		retired: make(map[string]time.Time),
+	}
+}
+
+// Load minifies, fingerprints and precompresses every file under the store's
+// directory. The previous assets are kept if it fails.
+func (as *AssetStore) Load() error {
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("text/javascript", js.Minify)
+	m.AddFunc("image/svg+xml", svg.Minify)
+
+	assets := make(map[string]*Asset)
+	urls := make(map[string]string)
+
+	err := filepath.WalkDir(as.dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(as.dir, file)
+		if err != nil {
+			return err
+		}
+		asset, err := buildAsset(m, file, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+
+		fingerprinted := *asset
+		fingerprinted.Fingerprinted = true
+		hashedName := fingerprintName(asset.Name, asset.Hash)
+
+		assets[asset.Name] = asset
+		assets[hashedName] = &fingerprinted
+		urls[asset.Name] = "/static/" + hashedName
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	retired := as.retire(assets, time.Now())
+	as.assets = assets
+	as.urls = urls
+	as.retired = retired
+	as.mutex.Unlock()
+
+	log.Printf("assets: loaded %d files from %s, %d previous versions kept", len(urls), as.dir, len(retired))
+	return nil
+}
+
+// retire copies the fingerprinted assets that the new load no longer has into
+// assets, along with those retired before that have not expired, and returns
+// their expiry. as.mutex must be held.
+func (as *AssetStore) retire(assets map[string]*Asset, now time.Time) map[string]time.Time {
+	retired := make(map[string]time.Time)
+	for name, asset := range as.assets {
+		if !asset.Fingerprinted {
+			continue
+		}
+		if _, exists := assets[name]; exists {
+			continue
+		}
+
+		expires, wasRetired := as.retired[name]
+		if !wasRetired {
+			expires = now.Add(retiredAssetLifetime)
+		}
+		if !expires.After(now) {
+			continue
+		}
+		assets[name] = asset
+		retired[name] = expires
+	}
+	return retired
+}
+
+func buildAsset(m *minify.M, file, name string) (*Asset, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	if _, _, minifier := m.Match(mediaType); minifier != nil {
+		minified, err := m.Bytes(mediaType, content)
+		if err != nil {
+			// The original still works, only bigger
+			log.Printf("assets: minifying %s: %v", name, err)
+		} else {
+			content = minified
+		}
+	}
+
+	sum := sha256.Sum256(content)
+	asset := &Asset{
+		Name:        name,
+		ContentType: contentType,
+		Content:     content,
+		Encoded:     make(map[string][]byte),
+		Hash:        hex.EncodeToString(sum[:])[:assetHashLength],
+		ModTime:     info.ModTime(),
+	}
+
+	if gzipped, err := gzipBytes(content); err == nil && worthCompressing(content, gzipped) {
+		asset.Encoded["gzip"] = gzipped
+	}
+	if brotlied, err := brotliBytes(content); err == nil && worthCompressing(content, brotlied) {
+		asset.Encoded["br"] = brotlied
+	}
+	return asset, nil
+}
+
+// worthCompressing skips variants that barely shrink, such as those of
+// images that are compressed already
+func worthCompressing(content, compressed []byte) bool {
+	return len(compressed) < len(content)*9/10
+}
+
+func gzipBytes(content []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func brotliBytes(content []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	w := brotli.NewWriterLevel(&buffer, brotli.BestCompression)
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// fingerprintName puts the hash before the extension, css/style.css becoming
+// css/style.0123456789.css
+func fingerprintName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// ModTime returns the newest modification time of the loaded files
+func (as *AssetStore) ModTime() time.Time {
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+
+	var latest time.Time
+	for name, asset := range as.assets {
+		if _, retired := as.retired[name]; retired {
+			continue
+		}
+		if asset.ModTime.After(latest) {
+			latest = asset.ModTime
+		}
+	}
+	return latest
+}
+
+// Get returns the asset served under name, either its path under static/ or
+// its fingerprinted path. A fingerprinted path this process never loaded,
+// such as one from before a deploy, gets the current version of the file, not
+// marked as fingerprinted so it is not cached forever.
+func (as *AssetStore) Get(name string) (*Asset, bool) {
+	now := time.Now()
+
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+
+	if asset, exists := as.assets[name]; exists {
+		if expires, retired := as.retired[name]; !retired || expires.After(now) {
+			return asset, true
+		}
+	}
+
+	if plainName, ok := unfingerprintName(name); ok {
+		if asset, exists := as.assets[plainName]; exists && !asset.Fingerprinted {
+			return asset, true
+		}
+	}
+	return nil, false
+}
+
+// unfingerprintName undoes fingerprintName, reporting false for names
+// without a hash
+func unfingerprintName(name string) (string, bool) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	hash := path.Ext(base)
+	if len(hash) != assetHashLength+1 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(hash[1:]); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(base, hash) + ext, true
+}
+
+// URL returns the fingerprinted URL of a path under static/. Paths the
+// pipeline does not know, and every path before Load, keep their plain URL.
+func (as *AssetStore) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+
+	if url, exists := as.urls[name]; exists {
+		return url
+	}
+	return "/static/" + name
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnfingerprintName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"css/style.0123456789.css", "css/style.css", true},
+		{"app.abcdef0123.min.js", "", false},
+		{"app.min.abcdef0123.js", "app.min.js", true},
+		{"css/style.css", "", false},
+		{"css/style.012345678.css", "", false},
+		{"css/style.012345678g.css", "", false},
+		{"LICENSE.0123456789", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := unfingerprintName(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("unfingerprintName(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	for _, name := range []string{"css/style.css", "app.min.js", "favicon.ico"} {
+		if got, ok := unfingerprintName(fingerprintName(name, "0123456789")); !ok || got != name {
+			t.Errorf("unfingerprintName(fingerprintName(%q)) = %q, %v", name, got, ok)
+		}
+	}
+}
+
+func writeAsset(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssetStoreKeepsPreviousFingerprints(t *testing.T) {
+	dir := t.TempDir()
+	writeAsset(t, dir, "site.js", "console.log(1)")
+	store := NewAssetStore(dir)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	firstURL := store.URL("site.js")
+	first := strings.TrimPrefix(firstURL, "/static/")
+
+	writeAsset(t, dir, "site.js", "console.log(2)")
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	secondURL := store.URL("site.js")
+	second := strings.TrimPrefix(secondURL, "/static/")
+	if secondURL == firstURL {
+		t.Fatalf("URL %s unchanged after the content changed", secondURL)
+	}
+
+	tests := []struct {
+		name          string
+		content       string
+		fingerprinted bool
+	}{
+		{first, "console.log(1)", true},
+		{second, "console.log(2)", true},
+		{"site.js", "console.log(2)", false},
+	}
+	for _, tt := range tests {
+		asset, ok := store.Get(tt.name)
+		if !ok {
+			t.Errorf("%s not found", tt.name)
+			continue
+		}
+		if string(asset.Content) != tt.content || asset.Fingerprinted != tt.fingerprinted {
+			t.Errorf("%s served %q fingerprinted %v, want %q fingerprinted %v", tt.name, asset.Content, asset.Fingerprinted, tt.content, tt.fingerprinted)
+		}
+	}
+
+	// A third load keeps both previous versions
+	writeAsset(t, dir, "site.js", "console.log(3)")
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{first, second} {
+		if asset, ok := store.Get(name); !ok || !asset.Fingerprinted {
+			t.Errorf("%s no longer served after another load", name)
+		}
+	}
+	if len(store.retired) != 2 {
+		t.Errorf("%d retired names, want 2", len(store.retired))
+	}
+
+	// Going back to the first content makes its name current again
+	writeAsset(t, dir, "site.js", "console.log(1)")
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, retired := store.retired[first]; retired || store.URL("site.js") != firstURL {
+		t.Errorf("restored content served at %s, retired %v", store.URL("site.js"), retired)
+	}
+}
+
+func TestAssetStoreRetiredExpire(t *testing.T) {
+	dir := t.TempDir()
+	writeAsset(t, dir, "site.js", "console.log(1)")
+	store := NewAssetStore(dir)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	old := strings.TrimPrefix(store.URL("site.js"), "/static/")
+
+	writeAsset(t, dir, "site.js", "console.log(2)")
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	expires := store.retired[old]
+	if lifetime := time.Until(expires); lifetime < retiredAssetLifetime-time.Minute || lifetime > retiredAssetLifetime {
+		t.Fatalf("retired for %s, want %s", lifetime, retiredAssetLifetime)
+	}
+
+	// Once expired the old name is served like an unknown hash, with the
+	// current content and without immutable caching
+	store.retired[old] = time.Now().Add(-time.Second)
+	asset, ok := store.Get(old)
+	if !ok || asset.Fingerprinted || string(asset.Content) != "console.log(2)" {
+		t.Errorf("expired name served %v: %+v", ok, asset)
+	}
+
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := store.assets[old]; exists {
+		t.Error("expired name kept by the next load")
+	}
+}
+
+func TestAssetStoreUnknownFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	writeAsset(t, dir, "site.js", "console.log(1)")
+	store := NewAssetStore(dir)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A name from a build this process never loaded, as after a deploy
+	asset, ok := store.Get("site.0123456789.js")
+	if !ok || asset.Fingerprinted || string(asset.Content) != "console.log(1)" {
+		t.Errorf("unknown fingerprint served %v: %+v", ok, asset)
+	}
+
+	for _, name := range []string{"other.0123456789.js", "site.js.map", "site.0123.js"} {
+		if _, ok := store.Get(name); ok {
+			t.Errorf("%s found", name)
+		}
+	}
+}
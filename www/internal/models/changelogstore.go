@@ -0,0 +1,204 @@
+package models
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0x800a6/www/internal/metrics"
+)
+
+// ChangelogStore keeps the parsed changelog in memory and reloads it when the
+// file changes. A failed reload keeps serving the last good data and is
+// reported through Status.
+type ChangelogStore struct {
+	path        string
+	content     []byte
+	data        *ChangelogData
+	modTime     time.Time
+	checkedMod  time.Time
+	loadedAt    time.Time
+	loadErr     error
+	statFailed  bool
+	mutex       sync.RWMutex
+	watchTicker *time.Ticker
+	stopWatch   chan bool
+	onReload    []func()
+}
+
+// ChangelogStatus reports the state of the last changelog load
+type ChangelogStatus struct {
+	Path     string    `json:"path"`
+	Loaded   bool      `json:"loaded"`
+	LoadedAt time.Time `json:"loaded_at"`
+	ModTime  time.Time `json:"mod_time"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func NewChangelogStore(path string) *ChangelogStore {
+	cs := &ChangelogStore{
+		path: path,
+		data: &ChangelogData{Entries: []ChangelogEntry{}},
+	}
+
+	if err := cs.Load(); err != nil {
+		log.Printf("changelog: %v", err)
+	}
+
+	return cs
+}
+
+// Load reads and parses the changelog file, replacing the stored data on success
+func (cs *ChangelogStore) Load() error {
+	info, err := os.Stat(cs.path)
+	if err != nil {
+		return cs.failLoad(err)
+	}
+
+	content, err := os.ReadFile(cs.path)
+	if err != nil {
+		return cs.failLoad(err)
+	}
+
+	data, err := ParseChangelog(string(content))
+	if err != nil {
+		return cs.failLoad(err)
+	}
+
+	cs.mutex.Lock()
+	cs.content = content
+	cs.data = data
+	cs.modTime = info.ModTime()
+	cs.checkedMod = info.ModTime()
+	cs.loadedAt = time.Now()
+	cs.loadErr = nil
+	hooks := cs.onReload
+	cs.mutex.Unlock()
+
+	metrics.ChangelogReloads.Inc("success")
+
+	for _, fn := range hooks {
+		fn()
+	}
+
+	return nil
+}
+
+// OnReload registers a function called after every successful load
+func (cs *ChangelogStore) OnReload(fn func()) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.onReload = append(cs.onReload, fn)
+}
+
+func (cs *ChangelogStore) failLoad(err error) error {
+	metrics.ChangelogReloads.Inc("failure")
+	return cs.fail(err)
+}
+
+func (cs *ChangelogStore) fail(err error) error {
+	cs.mutex.Lock()
+	cs.loadErr = err
+	cs.mutex.Unlock()
+	return err
+}
+
+// Data returns the last successfully parsed changelog
+func (cs *ChangelogStore) Data() *ChangelogData {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+	return cs.data
+}
+
+// Content returns the raw markdown of the last successful load
+func (cs *ChangelogStore) Content() []byte {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+	return cs.content
+}
+
+// ModTime returns the modification time of the last successfully loaded file
+func (cs *ChangelogStore) ModTime() time.Time {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+	return cs.modTime
+}
+
+func (cs *ChangelogStore) Status() ChangelogStatus {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+
+	status := ChangelogStatus{
+		Path:     cs.path,
+		Loaded:   !cs.loadedAt.IsZero(),
+		LoadedAt: cs.loadedAt,
+		ModTime:  cs.modTime,
+	}
+	if cs.loadErr != nil {
+		status.Error = cs.loadErr.Error()
+	}
+	return status
+}
+
+// Watch starts polling the changelog modification time
+func (cs *ChangelogStore) Watch(interval time.Duration) {
+	if cs.watchTicker != nil {
+		return
+	}
+
+	cs.watchTicker = time.NewTicker(interval)
+	cs.stopWatch = make(chan bool)
+	go cs.watch()
+}
+
+func (cs *ChangelogStore) Stop() {
+	if cs.watchTicker == nil {
+		return
+	}
+	cs.stopWatch <- true
+}
+
+func (cs *ChangelogStore) watch() {
+	for {
+		select {
+		case <-cs.watchTicker.C:
+			cs.check()
+		case <-cs.stopWatch:
+			cs.watchTicker.Stop()
+			return
+		}
+	}
+}
+
+// check reloads the changelog if its modification time changed, or if the
+// previous check could not stat it, since a file put back in place may keep
+// the modification time it had
+func (cs *ChangelogStore) check() {
+	info, err := os.Stat(cs.path)
+	if err != nil {
+		cs.mutex.Lock()
+		cs.statFailed = true
+		cs.mutex.Unlock()
+		cs.fail(err)
+		return
+	}
+
+	// checkedMod also advances on failed loads so a broken file is only
+	// retried once it changes again
+	cs.mutex.Lock()
+	changed := cs.statFailed || !info.ModTime().Equal(cs.checkedMod)
+	cs.checkedMod = info.ModTime()
+	cs.statFailed = false
+	cs.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err := cs.Load(); err != nil {
+		log.Printf("changelog: reload failed: %v", err)
+		return
+	}
+	log.Println("changelog: reloaded")
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testChangelog = "# Changelog\n\n## [0.1.0] - 2025-10-05\n\n### Added\n\n- First\n"
+
+func TestChangelogStoreCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CHANGELOG.md")
+	modTime := time.Date(2025, 10, 5, 12, 0, 0, 0, time.UTC)
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(testChangelog, modTime)
+	store := NewChangelogStore(path)
+	reloads := 0
+	store.OnReload(func() { reloads++ })
+
+	steps := []struct {
+		name     string
+		change   func()
+		wantErr  bool
+		reloaded bool
+	}{
+		{name: "unchanged", change: func() {}},
+		{name: "removed", change: func() { os.Remove(path) }, wantErr: true},
+		{name: "still missing", change: func() {}, wantErr: true},
+		// Restored with its old modification time, as by a backup or a
+		// deploy preserving times
+		{name: "restored", change: func() { write(testChangelog, modTime) }, reloaded: true},
+		{name: "unchanged after restore", change: func() {}},
+		// A directory in its place can be stat'ed but not read
+		{name: "unreadable", change: func() { os.Remove(path); os.Mkdir(path, 0o755) }, wantErr: true},
+		// A failed load is only retried once the file changes
+		{name: "still unreadable", change: func() {}, wantErr: true},
+		{name: "fixed", change: func() { os.Remove(path); write(testChangelog, modTime.Add(time.Minute)) }, reloaded: true},
+	}
+
+	for _, step := range steps {
+		before := reloads
+		step.change()
+		store.check()
+
+		status := store.Status()
+		if (status.Error != "") != step.wantErr {
+			t.Errorf("%s: status error %q, want error %v", step.name, status.Error, step.wantErr)
+		}
+		if reloaded := reloads > before; reloaded != step.reloaded {
+			t.Errorf("%s: reloaded %v, want %v", step.name, reloaded, step.reloaded)
+		}
+		if len(store.Data().Entries) != 1 {
+			t.Errorf("%s: %d entries served, want the last good changelog", step.name, len(store.Data().Entries))
+		}
+	}
+}
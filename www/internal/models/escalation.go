@@ -0,0 +1,231 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// EscalationConfig turns repeated rejections into bans. A key refused Strikes
+// times within Period is banned for Penalty, and each further ban of the key
+// doubles the penalty up to MaxPenalty. A key that has not been banned for
+// Forget starts over at the first penalty. Strikes of 0 disable escalation.
+type EscalationConfig struct {
+	Strikes    int
+	Period     time.Duration
+	Penalty    time.Duration
+	MaxPenalty time.Duration
+	Forget     time.Duration
+}
+
+// penalty is the ban duration of the given level, starting at 1
+func (c EscalationConfig) penalty(level int) time.Duration {
+	penalty := c.Penalty
+	for i := 1; i < level && penalty < c.MaxPenalty; i++ {
+		penalty *= 2
+	}
+	return min(penalty, c.MaxPenalty)
+}
+
+// Penalty is a ban a key is serving, escalated or set by an administrator
+type Penalty struct {
+	Level  int       `json:"level"`
+	Until  time.Time `json:"until"`
+	Manual bool      `json:"manual"`
+}
+
+// Remaining is the time left on the penalty, in whole seconds
+func (p Penalty) Remaining() time.Duration {
+	return max(time.Until(p.Until), 0).Round(time.Second)
+}
+
+type offender struct {
+	strikes []time.Time
+	level   int
+	until   time.Time
+	manual  bool
+}
+
+// Escalator counts the rejections of each key and bans repeat offenders
+// through the limiter, so the bans apply to every instance sharing it. The
+// strikes themselves are counted per instance.
+type Escalator struct {
+	limiter       Limiter
+	config        EscalationConfig
+	offenders     map[string]*offender
+	mutex         sync.Mutex
+	cleanupTicker *time.Ticker
+	stopCleanup   chan bool
+}
+
+func NewEscalator(limiter Limiter, config EscalationConfig) *Escalator {
+	e := &Escalator{
+		limiter:       limiter,
+		config:        config,
+		offenders:     make(map[string]*offender),
+		cleanupTicker: time.NewTicker(max(config.Period, time.Minute)),
+		stopCleanup:   make(chan bool),
+	}
+
+	go e.cleanupRoutine()
+
+	return e
+}
+
+// Strike records a rejection of key. When it is one too many, the key is
+// banned and the penalty returned.
+func (e *Escalator) Strike(key string) (Penalty, bool, error) {
+	penalty, banned, duration := e.strike(key, time.Now())
+	if !banned {
+		return Penalty{}, false, nil
+	}
+	return penalty, true, e.limiter.Ban(key, duration)
+}
+
+// strike counts a rejection at now and returns the ban it earns, if any
+func (e *Escalator) strike(key string, now time.Time) (Penalty, bool, time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.config.Strikes <= 0 {
+		return Penalty{}, false, 0
+	}
+
+	o := e.offender(key)
+	if o.level > 0 && now.Sub(o.until) > e.config.Forget {
+		o.level = 0
+	}
+	o.strikes = append(o.recentStrikes(now, e.config.Period), now)
+
+	if len(o.strikes) < e.config.Strikes {
+		return Penalty{}, false, 0
+	}
+
+	o.strikes = nil
+	o.level++
+	duration := e.config.penalty(o.level)
+	o.until = now.Add(duration)
+	o.manual = false
+	return o.penalty(), true, duration
+}
+
+// Ban bans key for duration through the limiter and records it, so the key
+// is shown its penalty like an escalated one. The penalty level is unchanged.
+func (e *Escalator) Ban(key string, duration time.Duration) (Penalty, error) {
+	if err := e.limiter.Ban(key, duration); err != nil {
+		return Penalty{}, err
+	}
+	return e.ban(key, duration, time.Now()), nil
+}
+
+func (e *Escalator) ban(key string, duration time.Duration, now time.Time) Penalty {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	o := e.offender(key)
+	o.until = now.Add(duration)
+	o.manual = true
+	return o.penalty()
+}
+
+// offender returns the record of key, creating it. e.mutex must be held.
+func (e *Escalator) offender(key string) *offender {
+	o, exists := e.offenders[key]
+	if !exists {
+		o = &offender{}
+		e.offenders[key] = o
+	}
+	return o
+}
+
+func (o *offender) penalty() Penalty {
+	return Penalty{Level: o.level, Until: o.until, Manual: o.manual}
+}
+
+// recentStrikes drops the strikes older than period
+func (o *offender) recentStrikes(now time.Time, period time.Duration) []time.Time {
+	cutoff := now.Add(-period)
+	kept := o.strikes[:0]
+	for _, t := range o.strikes {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// Penalty returns the penalty key is serving, if any
+func (e *Escalator) Penalty(key string) (Penalty, bool) {
+	return e.penaltyAt(key, time.Now())
+}
+
+func (e *Escalator) penaltyAt(key string, now time.Time) (Penalty, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	o, exists := e.offenders[key]
+	if !exists || !o.until.After(now) {
+		return Penalty{}, false
+	}
+	return o.penalty(), true
+}
+
+// Forgive forgets the strikes and penalty level of key. The ban itself is
+// lifted through the limiter.
+func (e *Escalator) Forgive(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	delete(e.offenders, key)
+}
+
+// OffenderCount returns the number of keys with strikes or a penalty level
+func (e *Escalator) OffenderCount() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return len(e.offenders)
+}
+
+func (e *Escalator) cleanupRoutine() {
+	for {
+		select {
+		case <-e.cleanupTicker.C:
+			e.cleanup(time.Now())
+		case <-e.stopCleanup:
+			e.cleanupTicker.Stop()
+			return
+		}
+	}
+}
+
+// cleanup drops keys whose strikes have expired and whose level would be
+// forgotten anyway
+func (e *Escalator) cleanup(now time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for key, o := range e.offenders {
+		o.strikes = o.recentStrikes(now, e.config.Period)
+		if len(o.strikes) == 0 && now.Sub(o.until) > e.config.Forget {
+			delete(e.offenders, key)
+		}
+	}
+}
+
+func (e *Escalator) GetConfig() EscalationConfig {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.config
+}
+
+// SetConfig swaps the thresholds. Strikes and levels already counted are kept.
+func (e *Escalator) SetConfig(config EscalationConfig) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.config = config
+	e.cleanupTicker.Reset(max(config.Period, time.Minute))
+}
+
+func (e *Escalator) Stop() {
+	e.stopCleanup <- true
+}
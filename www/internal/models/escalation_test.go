@@ -0,0 +1,226 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestEscalator(t *testing.T, config EscalationConfig) (*Escalator, *RateLimiter) {
+	t.Helper()
+	limiter := newTestLimiter(t)
+	e := NewEscalator(limiter, config)
+	t.Cleanup(e.Stop)
+	return e, limiter
+}
+
+// testEscalation bans after 3 strikes within 10 seconds, for a minute doubling
+// up to 5 minutes, and forgets after an hour
+var testEscalation = EscalationConfig{
+	Strikes:    3,
+	Period:     10 * time.Second,
+	Penalty:    time.Minute,
+	MaxPenalty: 5 * time.Minute,
+	Forget:     time.Hour,
+}
+
+func TestEscalatorStrike(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := time.Second
+	minute := time.Minute
+
+	// Each step is one strike at start+at, and the ban it must earn, if any
+	steps := []struct {
+		at       time.Duration
+		level    int
+		duration time.Duration
+	}{
+		{0, 0, 0},
+		{4 * second, 0, 0},
+		{9 * second, 1, minute},
+		// The ban clears the strikes
+		{10 * second, 0, 0},
+		{11 * second, 0, 0},
+		// The strike at 10s is more than the period old
+		{20 * second, 0, 0},
+		{21 * second, 0, 0},
+		{25 * second, 2, 2 * minute},
+		{30 * second, 0, 0},
+		{30 * second, 0, 0},
+		{30 * second, 3, 4 * minute},
+		// The penalty stops doubling at the maximum
+		{40 * second, 0, 0},
+		{40 * second, 0, 0},
+		{40 * second, 4, 5 * minute},
+		{50 * second, 0, 0},
+		{50 * second, 0, 0},
+		{50 * second, 5, 5 * minute},
+		// Forgotten an hour after the last ban ended, starting over at the
+		// first penalty
+		{50*second + 5*minute + time.Hour + second, 0, 0},
+		{50*second + 5*minute + time.Hour + second, 0, 0},
+		{50*second + 5*minute + time.Hour + second, 1, minute},
+	}
+
+	e, _ := newTestEscalator(t, testEscalation)
+	for i, step := range steps {
+		now := start.Add(step.at)
+		penalty, banned, duration := e.strike("client", now)
+
+		if banned != (step.level > 0) {
+			t.Fatalf("step %d at %s: banned %v, want %v", i, step.at, banned, step.level > 0)
+		}
+		if !banned {
+			continue
+		}
+		if penalty.Level != step.level || duration != step.duration || !penalty.Until.Equal(now.Add(step.duration)) {
+			t.Errorf("step %d at %s: level %d for %s until %s, want level %d for %s", i, step.at, penalty.Level, duration, penalty.Until, step.level, step.duration)
+		}
+		if penalty.Manual {
+			t.Errorf("step %d at %s: escalated ban marked manual", i, step.at)
+		}
+	}
+
+	// Strikes are counted per key
+	if _, banned, _ := e.strike("other", start); banned {
+		t.Error("first strike of another key banned it")
+	}
+}
+
+func TestEscalatorNotForgottenWhileBanned(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	e, _ := newTestEscalator(t, testEscalation)
+
+	for i := 0; i < testEscalation.Strikes; i++ {
+		e.strike("client", start)
+	}
+	// Within an hour of the ban ending the level is kept
+	later := start.Add(time.Minute + 59*time.Minute)
+	for i := 0; i < testEscalation.Strikes-1; i++ {
+		e.strike("client", later)
+	}
+	penalty, banned, duration := e.strike("client", later)
+	if !banned || penalty.Level != 2 || duration != 2*time.Minute {
+		t.Errorf("ban within the forget period: banned %v level %d for %s, want level 2 for 2m", banned, penalty.Level, duration)
+	}
+}
+
+func TestEscalatorPenalty(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	e, _ := newTestEscalator(t, testEscalation)
+
+	if _, ok := e.penaltyAt("client", start); ok {
+		t.Error("unknown key has a penalty")
+	}
+
+	e.strike("client", start)
+	e.strike("client", start)
+	if _, ok := e.penaltyAt("client", start); ok {
+		t.Error("key with strikes but no ban has a penalty")
+	}
+
+	e.strike("client", start)
+	tests := []struct {
+		at time.Duration
+		ok bool
+	}{
+		{0, true},
+		{59 * time.Second, true},
+		{time.Minute, false},
+		{time.Hour, false},
+	}
+	for _, tt := range tests {
+		penalty, ok := e.penaltyAt("client", start.Add(tt.at))
+		if ok != tt.ok {
+			t.Errorf("penalty at %s: %v, want %v", tt.at, ok, tt.ok)
+		}
+		if ok && (penalty.Level != 1 || !penalty.Until.Equal(start.Add(time.Minute))) {
+			t.Errorf("penalty at %s: level %d until %s", tt.at, penalty.Level, penalty.Until)
+		}
+	}
+
+	e.Forgive("client")
+	if _, ok := e.penaltyAt("client", start); ok || e.OffenderCount() != 0 {
+		t.Errorf("forgiven key still has a penalty, %d offenders", e.OffenderCount())
+	}
+	// Forgiving also resets the level
+	for i := 0; i < testEscalation.Strikes; i++ {
+		e.strike("client", start)
+	}
+	if penalty, _ := e.penaltyAt("client", start); penalty.Level != 1 {
+		t.Errorf("level %d after forgiving, want 1", penalty.Level)
+	}
+}
+
+func TestEscalatorBan(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	e, limiter := newTestEscalator(t, testEscalation)
+
+	penalty, err := e.Ban("admin", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !penalty.Manual || penalty.Level != 0 {
+		t.Errorf("admin ban %+v, want manual at level 0", penalty)
+	}
+	if shown, ok := e.Penalty("admin"); !ok || shown != penalty {
+		t.Errorf("penalty shown %+v, %v, want the admin ban", shown, ok)
+	}
+	if result, err := limiter.Allow(DefaultPolicy, "admin"); err != nil || !result.Banned {
+		t.Errorf("limiter result %+v, %v, want the key banned", result, err)
+	}
+
+	// An admin ban keeps the escalation level, and the next escalated ban
+	// is no longer manual
+	for i := 0; i < testEscalation.Strikes; i++ {
+		e.strike("client", start)
+	}
+	penalty = e.ban("client", 10*time.Minute, start.Add(time.Second))
+	if !penalty.Manual || penalty.Level != 1 || !penalty.Until.Equal(start.Add(time.Second+10*time.Minute)) {
+		t.Errorf("admin ban of an offender %+v", penalty)
+	}
+	for i := 0; i < testEscalation.Strikes; i++ {
+		penalty, _, _ = e.strike("client", start.Add(2*time.Second))
+	}
+	if penalty.Manual || penalty.Level != 2 {
+		t.Errorf("escalated ban after an admin ban %+v", penalty)
+	}
+
+	// Admin bans work with escalation disabled
+	e.SetConfig(EscalationConfig{})
+	if _, banned, _ := e.strike("disabled", start); banned {
+		t.Error("strike banned with escalation disabled")
+	}
+	if _, err := e.Ban("disabled", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Penalty("disabled"); !ok {
+		t.Error("admin ban with escalation disabled has no penalty")
+	}
+}
+
+func TestEscalatorCleanup(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	e, _ := newTestEscalator(t, testEscalation)
+
+	e.strike("striker", start)
+	for i := 0; i < testEscalation.Strikes; i++ {
+		e.strike("banned", start)
+	}
+
+	tests := []struct {
+		at   time.Duration
+		want int
+	}{
+		{5 * time.Second, 2},
+		// The strike expired, the ban is remembered until it is forgotten
+		{10 * time.Second, 1},
+		{time.Minute + time.Hour, 1},
+		{time.Minute + time.Hour + time.Second, 0},
+	}
+	for _, tt := range tests {
+		e.cleanup(start.Add(tt.at))
+		if got := e.OffenderCount(); got != tt.want {
+			t.Errorf("after cleanup at %s: %d offenders, want %d", tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package models
+
+import (
+	"encoding/xml"
+	"html"
+	"strings"
+	"time"
+)
+
+// Feed represents a syndication feed independent of its output format, so
+// RSS, Atom and JSON Feed are all generated from the same items
+type Feed struct {
+	Title       string
+	Description string
+	Link        string
+	Language    string
+	Author      string
+	Updated     time.Time
+	Items       []FeedItem
+}
+
+// FeedItem represents a single feed entry
+type FeedItem struct {
+	ID          string
+	Title       string
+	Link        string
+	Published   time.Time
+	ContentHTML string
+	Categories  []string
+}
+
+// FeedInfo describes the site a feed is generated for
+type FeedInfo struct {
+	Title       string
+	Description string
+	Author      string
+	Link        string
+}
+
+// NewChangelogFeed builds a feed from the most recent changelog entries
+func NewChangelogFeed(cd *ChangelogData, info FeedInfo, maxEntries int) *Feed {
+	feed := &Feed{
+		Title:       info.Title,
+		Description: info.Description,
+		Link:        info.Link,
+		Language:    "en-us",
+		Author:      info.Author,
+		Items:       []FeedItem{},
+	}
+
+	if len(cd.Entries) < maxEntries {
+		maxEntries = len(cd.Entries)
+	}
+
+	for _, entry := range cd.Entries[:maxEntries] {
+		item := FeedItem{
+			ID:          info.Link + "#" + entry.Version,
+			Title:       "Version " + entry.Version,
+			Link:        info.Link + "#" + entry.Version,
+			Published:   entry.Date,
+			ContentHTML: changesHTML(entry.Changes),
+			Categories:  []string{},
+		}
+		for _, change := range entry.Changes {
+			item.Categories = append(item.Categories, change.Type)
+		}
+
+		if entry.Date.After(feed.Updated) {
+			feed.Updated = entry.Date
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	if feed.Updated.IsZero() {
+		feed.Updated = time.Now().UTC()
+	}
+
+	return feed
+}
+
+// changesHTML renders the changes of an entry as escaped HTML
+func changesHTML(changes []Change) string {
+	var description strings.Builder
+	for _, change := range changes {
+		description.WriteString("<h3>" + html.EscapeString(change.Type) + "</h3>")
+		description.WriteString("<ul>")
+		for _, item := range change.Items {
+			description.WriteString("<li>" + html.EscapeString(item) + "</li>")
+		}
+		description.WriteString("</ul>")
+	}
+	return description.String()
+}
+
+// RSSFeed represents an RSS 2.0 feed document
+type RSSFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel RSSChannel `xml:"channel"`
+}
+
+type RSSChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      RSSLink   `xml:"atom:link"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []RSSItem `xml:"item"`
+}
+
+// RSSLink represents the atom:link element pointing at the feed itself
+type RSSLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type RSSItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        RSSGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type RSSGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS converts the feed into an RSS 2.0 document served from selfURL. Item
+// descriptions carry the escaped HTML body, as RSS readers expect.
+func (f *Feed) RSS(selfURL string) *RSSFeed {
+	rss := &RSSFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: RSSChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			AtomLink:      RSSLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+			Language:      f.Language,
+			LastBuildDate: f.Updated.Format(time.RFC1123Z),
+			Items:         []RSSItem{},
+		},
+	}
+
+	for _, item := range f.Items {
+		rssItem := RSSItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        RSSGUID{IsPermaLink: true, Value: item.ID},
+			Categories:  item.Categories,
+			Description: item.ContentHTML,
+		}
+		if !item.Published.IsZero() {
+			rssItem.PubDate = item.Published.Format(time.RFC1123Z)
+		}
+
+		rss.Channel.Items = append(rss.Channel.Items, rssItem)
+	}
+
+	return rss
+}
+
+// AtomFeed represents an Atom 1.0 feed document
+type AtomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []AtomLink  `xml:"link"`
+	Author   *AtomPerson `xml:"author,omitempty"`
+	Entries  []AtomEntry `xml:"entry"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+type AtomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []AtomLink     `xml:"link"`
+	Categories []AtomCategory `xml:"category"`
+	Content    AtomContent    `xml:"content"`
+}
+
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type AtomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Atom converts the feed into an Atom 1.0 document served from selfURL
+func (f *Feed) Atom(selfURL string) *AtomFeed {
+	atom := &AtomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       selfURL,
+		Updated:  f.Updated.Format(time.RFC3339),
+		Links: []AtomLink{
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: []AtomEntry{},
+	}
+
+	if f.Author != "" {
+		atom.Author = &AtomPerson{Name: f.Author}
+	}
+
+	for _, item := range f.Items {
+		// Atom requires an updated timestamp on every entry
+		updated := item.Published
+		if updated.IsZero() {
+			updated = f.Updated
+		}
+
+		entry := AtomEntry{
+			Title:   item.Title,
+			ID:      item.ID,
+			Updated: updated.Format(time.RFC3339),
+			Links:   []AtomLink{{Href: item.Link, Rel: "alternate", Type: "text/html"}},
+			Content: AtomContent{Type: "html", Body: item.ContentHTML},
+		}
+		if !item.Published.IsZero() {
+			entry.Published = item.Published.Format(time.RFC3339)
+		}
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, AtomCategory{Term: category})
+		}
+
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	return atom
+}
+
+// JSONFeed represents a JSON Feed 1.1 document
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Language    string           `json:"language,omitempty"`
+	Authors     []JSONFeedAuthor `json:"authors,omitempty"`
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type JSONFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	ContentHTML   string   `json:"content_html"`
+	DatePublished string   `json:"date_published,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// JSONFeed converts the feed into a JSON Feed 1.1 document served from feedURL
+func (f *Feed) JSONFeed(feedURL string) *JSONFeed {
+	jsonFeed := &JSONFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     feedURL,
+		Description: f.Description,
+		Language:    f.Language,
+		Items:       []JSONFeedItem{},
+	}
+
+	if f.Author != "" {
+		jsonFeed.Authors = []JSONFeedAuthor{{Name: f.Author}}
+	}
+
+	for _, item := range f.Items {
+		jsonItem := JSONFeedItem{
+			ID:          item.ID,
+			URL:         item.Link,
+			Title:       item.Title,
+			ContentHTML: item.ContentHTML,
+			Tags:        item.Categories,
+		}
+		if !item.Published.IsZero() {
+			jsonItem.DatePublished = item.Published.Format(time.RFC3339)
+		}
+
+		jsonFeed.Items = append(jsonFeed.Items, jsonItem)
+	}
+
+	return jsonFeed
+}
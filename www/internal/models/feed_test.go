@@ -0,0 +1,155 @@
+package models
+
+import (
+	"encoding/xml"
+	"html"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trickyItems are changelog lines that break a feed built by string
+// concatenation or wrapped in CDATA
+var trickyItems = []string{
+	`Fixed "quoted" & 'single quoted' titles`,
+	`Escaped <script>alert(1)</script> in descriptions`,
+	`Survived a literal ]]> in the text`,
+	`Kept &amp; as typed`,
+}
+
+func testChangelogFeed(t *testing.T) *Feed {
+	t.Helper()
+	data := &ChangelogData{
+		Entries: []ChangelogEntry{
+			{
+				Version: "1.2.0",
+				Date:    time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC),
+				Changes: []Change{
+					{Type: "Fixed", Items: trickyItems},
+					{Type: "Q&A <docs>", Items: []string{"Answered ]]> questions"}},
+				},
+			},
+			{
+				Version: "1.1.0",
+				Date:    time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC),
+				Changes: []Change{{Type: "Added", Items: []string{"Feeds"}}},
+			},
+			{
+				Version: "1.0.0",
+				Date:    time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+				Changes: []Change{{Type: "Added", Items: []string{"Everything"}}},
+			},
+		},
+	}
+	return NewChangelogFeed(data, FeedInfo{
+		Title:       `Changes & "news"`,
+		Description: "What <changed>",
+		Author:      "Example",
+		Link:        "https://example.com/changelog",
+	}, 2)
+}
+
+func TestNewChangelogFeed(t *testing.T) {
+	feed := testChangelogFeed(t)
+
+	if len(feed.Items) != 2 {
+		t.Fatalf("feed has %d items, want the 2 most recent", len(feed.Items))
+	}
+	if want := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC); !feed.Updated.Equal(want) {
+		t.Errorf("feed updated %s, want the newest entry's %s", feed.Updated, want)
+	}
+
+	item := feed.Items[0]
+	if item.ID != "https://example.com/changelog#1.2.0" || item.Link != item.ID {
+		t.Errorf("item ID %q and link %q, want the version anchor", item.ID, item.Link)
+	}
+	if !slices.Equal(item.Categories, []string{"Fixed", "Q&A <docs>"}) {
+		t.Errorf("item categories %q", item.Categories)
+	}
+	for _, unsafe := range []string{"<script>", `"quoted"`, "]]>"} {
+		if strings.Contains(item.ContentHTML, unsafe) {
+			t.Errorf("item HTML contains unescaped %q: %s", unsafe, item.ContentHTML)
+		}
+	}
+	if unescaped := html.UnescapeString(item.ContentHTML); !strings.Contains(unescaped, trickyItems[1]) {
+		t.Errorf("item HTML lost the change text: %s", unescaped)
+	}
+}
+
+func TestFeedRSSRoundTrip(t *testing.T) {
+	feed := testChangelogFeed(t)
+
+	content, err := xml.Marshal(feed.RSS("https://example.com/changelog.rss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rss RSSFeed
+	if err := xml.Unmarshal(content, &rss); err != nil {
+		t.Fatalf("RSS does not parse: %v\n%s", err, content)
+	}
+
+	if rss.Version != "2.0" || rss.Channel.Title != feed.Title || rss.Channel.Description != feed.Description {
+		t.Errorf("channel = %q %q %q", rss.Version, rss.Channel.Title, rss.Channel.Description)
+	}
+	if len(rss.Channel.Items) != len(feed.Items) {
+		t.Fatalf("RSS has %d items, want %d", len(rss.Channel.Items), len(feed.Items))
+	}
+	for i, item := range rss.Channel.Items {
+		want := feed.Items[i]
+		if item.Description != want.ContentHTML {
+			t.Errorf("item %d description\n got %s\nwant %s", i, item.Description, want.ContentHTML)
+		}
+		if !slices.Equal(item.Categories, want.Categories) {
+			t.Errorf("item %d categories %q, want %q", i, item.Categories, want.Categories)
+		}
+		if item.GUID.Value != want.ID || !item.GUID.IsPermaLink {
+			t.Errorf("item %d guid %+v, want permalink %q", i, item.GUID, want.ID)
+		}
+		if published, err := time.Parse(time.RFC1123Z, item.PubDate); err != nil || !published.Equal(want.Published) {
+			t.Errorf("item %d pubDate %q, want %s", i, item.PubDate, want.Published)
+		}
+	}
+}
+
+func TestFeedAtomRoundTrip(t *testing.T) {
+	feed := testChangelogFeed(t)
+
+	content, err := xml.Marshal(feed.Atom("https://example.com/changelog.atom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var atom AtomFeed
+	if err := xml.Unmarshal(content, &atom); err != nil {
+		t.Fatalf("Atom does not parse: %v\n%s", err, content)
+	}
+
+	if atom.Title != feed.Title || atom.Subtitle != feed.Description || atom.ID != "https://example.com/changelog.atom" {
+		t.Errorf("feed = %q %q %q", atom.Title, atom.Subtitle, atom.ID)
+	}
+	if atom.Author == nil || atom.Author.Name != feed.Author {
+		t.Errorf("author = %+v, want %q", atom.Author, feed.Author)
+	}
+	if len(atom.Entries) != len(feed.Items) {
+		t.Fatalf("Atom has %d entries, want %d", len(atom.Entries), len(feed.Items))
+	}
+	for i, entry := range atom.Entries {
+		want := feed.Items[i]
+		if entry.Content.Type != "html" || entry.Content.Body != want.ContentHTML {
+			t.Errorf("entry %d content (%s)\n got %s\nwant %s", i, entry.Content.Type, entry.Content.Body, want.ContentHTML)
+		}
+
+		var categories []string
+		for _, category := range entry.Categories {
+			categories = append(categories, category.Term)
+		}
+		if !slices.Equal(categories, want.Categories) {
+			t.Errorf("entry %d categories %q, want %q", i, categories, want.Categories)
+		}
+		if updated, err := time.Parse(time.RFC3339, entry.Updated); err != nil || !updated.Equal(want.Published) {
+			t.Errorf("entry %d updated %q, want %s", i, entry.Updated, want.Published)
+		}
+	}
+}
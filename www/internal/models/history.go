@@ -0,0 +1,79 @@
+package models
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// historyMinutes is how far back rejection history goes
+const historyMinutes = 60
+
+// RejectionSeries counts the requests one policy refused per minute, oldest
+// first, ending with the current minute
+type RejectionSeries struct {
+	Policy string    `json:"policy"`
+	Start  time.Time `json:"start"`
+	Counts []uint64  `json:"counts"`
+}
+
+// rejectionHistory keeps per-minute rejection counts of the last hour in a
+// ring, so recording never allocates once a policy has been seen
+type rejectionHistory struct {
+	minutes [historyMinutes]int64
+	counts  map[string]*[historyMinutes]uint64
+	mutex   sync.Mutex
+}
+
+func (h *rejectionHistory) record(policy string, now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	minute := now.Unix() / 60
+	slot := minute % historyMinutes
+	if h.minutes[slot] != minute {
+		h.minutes[slot] = minute
+		for _, counts := range h.counts {
+			counts[slot] = 0
+		}
+	}
+
+	if h.counts == nil {
+		h.counts = make(map[string]*[historyMinutes]uint64)
+	}
+	counts, ok := h.counts[policy]
+	if !ok {
+		counts = &[historyMinutes]uint64{}
+		h.counts[policy] = counts
+	}
+	counts[slot]++
+}
+
+// series returns the history of every policy that refused a request,
+// sorted by policy name
+func (h *rejectionHistory) series(now time.Time) []RejectionSeries {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	last := now.Unix() / 60
+	first := last - historyMinutes + 1
+
+	series := []RejectionSeries{}
+	for policy, counts := range h.counts {
+		s := RejectionSeries{
+			Policy: policy,
+			Start:  time.Unix(first*60, 0),
+			Counts: make([]uint64, historyMinutes),
+		}
+		for minute := first; minute <= last; minute++ {
+			slot := minute % historyMinutes
+			if h.minutes[slot] == minute {
+				s.Counts[minute-first] = counts[slot]
+			}
+		}
+		series = append(series, s)
+	}
+
+	sort.Slice(series, func(i, j int) bool { return series[i].Policy < series[j].Policy })
+	return series
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Limiter decides whether a client key may make another request under a
+// named policy. Each policy counts separately. Backends share the same
+// semantics so RateLimitMiddleware works with any of them.
+type Limiter interface {
+	// Allow consumes one request for key
+	Allow(policy, key string) (RateLimitResult, error)
+	// Peek reports the state of key without consuming anything
+	Peek(policy, key string) (RateLimitResult, error)
+	// Reset forgets everything about key under policy
+	Reset(policy, key string) error
+	// Ban refuses every request of key, under any policy, for duration
+	Ban(key string, duration time.Duration) error
+	// Unban lifts a ban before it expires
+	Unban(key string) error
+	// Inspect lists every tracked key and active ban
+	Inspect() (Inspection, error)
+	Stats() (LimiterStats, error)
+}
+
+// RateLimitResult describes a key's budget after a request
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAt is when the full budget is available again
+	ResetAt time.Time
+	// RetryAfter is how long a refused client must wait, zero when allowed
+	RetryAfter time.Duration
+	// Window is the period in which Limit requests are allowed
+	Window time.Duration
+	// Banned is set when the key was refused because it is banned
+	Banned bool
+}
+
+// bannedResult is the result for a key banned until the given time
+func bannedResult(policy RateLimitPolicy, until, now time.Time) RateLimitResult {
+	return RateLimitResult{
+		Limit:      policy.BurstSize,
+		ResetAt:    until,
+		RetryAfter: until.Sub(now),
+		Window:     policy.window(),
+		Banned:     true,
+	}
+}
+
+// KeyState is the current budget of one key under one policy
+type KeyState struct {
+	Policy    string    `json:"policy"`
+	Key       string    `json:"key"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+	Throttled bool      `json:"throttled"`
+}
+
+// Used is how much of the budget the key has consumed
+func (ks KeyState) Used() int {
+	return ks.Limit - ks.Remaining
+}
+
+type Ban struct {
+	Key   string    `json:"key"`
+	Until time.Time `json:"until"`
+}
+
+// Inspection lists what a backend currently tracks, keys sorted by the
+// budget they used, most first, and bans by expiry
+type Inspection struct {
+	Keys []KeyState `json:"keys"`
+	Bans []Ban      `json:"bans"`
+}
+
+func (in *Inspection) sort() {
+	sort.Slice(in.Keys, func(i, j int) bool {
+		a, b := in.Keys[i], in.Keys[j]
+		if a.Used() != b.Used() {
+			return a.Used() > b.Used()
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Policy < b.Policy
+	})
+	sort.Slice(in.Bans, func(i, j int) bool { return in.Bans[i].Until.Before(in.Bans[j].Until) })
+}
+
+// LimiterStats summarizes a backend. Allowed and Rejected count decisions
+// made by this process only.
+type LimiterStats struct {
+	Backend  string `json:"backend"`
+	Keys     int    `json:"keys"`
+	Allowed  uint64 `json:"allowed"`
+	Rejected uint64 `json:"rejected"`
+	// Rejections breaks Rejected down by policy over the last hour
+	Rejections []RejectionSeries `json:"rejections"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// testLimiterBackend runs the behaviour every Limiter shares against a fresh
+// limiter configured with testLimiterConfig
+func testLimiterBackend(t *testing.T, limiter Limiter) {
+	for name, policy := range testLimiterConfig().Policies {
+		t.Run(string(policy.Algorithm), func(t *testing.T) {
+			key := "client-" + name
+
+			for i := range policy.BurstSize {
+				result, err := limiter.Allow(name, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !result.Allowed || result.Remaining != policy.BurstSize-1-i {
+					t.Fatalf("request %d: allowed %v with %d remaining", i+1, result.Allowed, result.Remaining)
+				}
+				if result.Limit != policy.BurstSize || result.RetryAfter != 0 {
+					t.Errorf("request %d: limit %d retry after %s", i+1, result.Limit, result.RetryAfter)
+				}
+			}
+
+			result, err := limiter.Allow(name, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed || result.Banned || result.Remaining != 0 {
+				t.Fatalf("request over the burst: allowed %v banned %v with %d remaining", result.Allowed, result.Banned, result.Remaining)
+			}
+			if result.RetryAfter <= 0 || result.RetryAfter > policy.window() {
+				t.Errorf("retry after %s, want within the %s window", result.RetryAfter, policy.window())
+			}
+			if !result.ResetAt.After(time.Now()) {
+				t.Errorf("reset at %s is not in the future", result.ResetAt)
+			}
+
+			peeked, err := limiter.Peek(name, "client-fresh")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !peeked.Allowed || peeked.Remaining != policy.BurstSize {
+				t.Errorf("peek of a new key: allowed %v with %d remaining", peeked.Allowed, peeked.Remaining)
+			}
+			if again, _ := limiter.Peek(name, "client-fresh"); again.Remaining != policy.BurstSize {
+				t.Errorf("peek consumed a request, %d remaining", again.Remaining)
+			}
+
+			inspection, err := limiter.Inspect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, state := range inspection.Keys {
+				if state.Policy == name && state.Key == key {
+					found = true
+					if !state.Throttled || state.Remaining != 0 || state.Used() != policy.BurstSize {
+						t.Errorf("inspected %+v, want throttled with the budget used", state)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("inspection %+v is missing %s", inspection.Keys, key)
+			}
+
+			if err := limiter.Reset(name, key); err != nil {
+				t.Fatal(err)
+			}
+			if result, _ := limiter.Allow(name, key); !result.Allowed || result.Remaining != policy.BurstSize-1 {
+				t.Errorf("after reset: allowed %v with %d remaining", result.Allowed, result.Remaining)
+			}
+		})
+	}
+
+	t.Run("ban", func(t *testing.T) {
+		if err := limiter.Ban("banned", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		for name := range testLimiterConfig().Policies {
+			result, err := limiter.Allow(name, "banned")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed || !result.Banned {
+				t.Errorf("%s: banned key allowed", name)
+			}
+			if result.RetryAfter <= 0 || result.RetryAfter > time.Minute {
+				t.Errorf("%s: banned key retry after %s", name, result.RetryAfter)
+			}
+		}
+
+		inspection, err := limiter.Inspect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(inspection.Bans) != 1 || inspection.Bans[0].Key != "banned" {
+			t.Errorf("inspected bans %+v", inspection.Bans)
+		} else if until := time.Until(inspection.Bans[0].Until); until <= 0 || until > time.Minute {
+			t.Errorf("ban expires in %s", until)
+		}
+
+		if err := limiter.Unban("banned"); err != nil {
+			t.Fatal(err)
+		}
+		if result, _ := limiter.Allow(DefaultPolicy, "banned"); !result.Allowed {
+			t.Error("unbanned key refused")
+		}
+	})
+
+	if _, err := limiter.Allow("missing", "client"); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	testLimiterBackend(t, newTestLimiter(t))
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Project represents a single project shown on the projects, resume and home pages
+type Project struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Icon        string        `json:"icon"`
+	Status      string        `json:"status"`
+	Language    string        `json:"language"`
+	Category    string        `json:"category"`
+	Featured    bool          `json:"featured"`
+	Resume      bool          `json:"resume"`
+	Tech        []string      `json:"tech"`
+	Links       []ProjectLink `json:"links"`
+}
+
+// ProjectLink represents an outbound project link, an empty URL renders it disabled
+type ProjectLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url,omitempty"`
+	Icon  string `json:"icon"`
+}
+
+// ProjectCategory represents a section of the projects page
+type ProjectCategory struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Icon  string `json:"icon"`
+}
+
+// ProjectSection groups the projects of one category for rendering
+type ProjectSection struct {
+	Category ProjectCategory
+	Projects []Project
+}
+
+// ProjectFilterOption represents a choice in the projects page filters
+type ProjectFilterOption struct {
+	Value string
+	Label string
+}
+
+// ProjectFilter represents filtering options for the projects catalog
+type ProjectFilter struct {
+	Query    string `json:"q"`
+	Status   string `json:"status"`
+	Language string `json:"language"`
+	Category string `json:"category"`
+	Tech     string `json:"tech"`
+}
+
+// ProjectCatalog represents the parsed projects data file
+type ProjectCatalog struct {
+	Categories []ProjectCategory `json:"categories"`
+	Projects   []Project         `json:"projects"`
+}
+
+// LoadProjects reads and validates a projects data file
+func LoadProjects(path string) (*ProjectCatalog, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := &ProjectCatalog{}
+	if err := json.Unmarshal(content, catalog); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	categories := make(map[string]bool)
+	for _, category := range catalog.Categories {
+		categories[category.ID] = true
+	}
+
+	for _, project := range catalog.Projects {
+		if project.Name == "" {
+			return nil, fmt.Errorf("parsing %s: project without a name", path)
+		}
+		if !categories[project.Category] {
+			return nil, fmt.Errorf("parsing %s: project %q has unknown category %q", path, project.Name, project.Category)
+		}
+	}
+
+	return catalog, nil
+}
+
+// StatusID returns the status as used in data attributes and CSS classes
+func (p Project) StatusID() string {
+	return slugify(p.Status)
+}
+
+// LanguageID returns the language as used in data attributes
+func (p Project) LanguageID() string {
+	return slugify(p.Language)
+}
+
+// TechIDs returns the comma separated tech list used in data attributes
+func (p Project) TechIDs() string {
+	ids := make([]string, len(p.Tech))
+	for i, tech := range p.Tech {
+		ids[i] = slugify(tech)
+	}
+	return strings.Join(ids, ",")
+}
+
+// FilterProjects applies filters to the catalog, keeping every category so
+// sections still render in their declared order
+func (pc *ProjectCatalog) FilterProjects(filter ProjectFilter) *ProjectCatalog {
+	filtered := []Project{}
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+
+	for _, project := range pc.Projects {
+		if filter.Status != "" && project.StatusID() != slugify(filter.Status) {
+			continue
+		}
+		if filter.Language != "" && project.LanguageID() != slugify(filter.Language) {
+			continue
+		}
+		if filter.Category != "" && project.Category != slugify(filter.Category) {
+			continue
+		}
+		if filter.Tech != "" && !project.hasTech(filter.Tech) {
+			continue
+		}
+		if query != "" && !project.matches(query) {
+			continue
+		}
+
+		filtered = append(filtered, project)
+	}
+
+	return &ProjectCatalog{
+		Categories: pc.Categories,
+		Projects:   filtered,
+	}
+}
+
+func (p Project) hasTech(tech string) bool {
+	tech = slugify(tech)
+	for _, t := range p.Tech {
+		if slugify(t) == tech {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the lowercased query appears in the name,
+// description or tech list
+func (p Project) matches(query string) bool {
+	text := strings.ToLower(p.Name + " " + p.Description + " " + strings.Join(p.Tech, " "))
+	return strings.Contains(text, query)
+}
+
+// GetSections returns the projects grouped by category, in category order,
+// leaving out empty categories
+func (pc *ProjectCatalog) GetSections() []ProjectSection {
+	sections := []ProjectSection{}
+	for _, category := range pc.Categories {
+		section := ProjectSection{Category: category}
+		for _, project := range pc.Projects {
+			if project.Category == category.ID {
+				section.Projects = append(section.Projects, project)
+			}
+		}
+		if len(section.Projects) > 0 {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// GetFeatured returns the projects highlighted on the home page
+func (pc *ProjectCatalog) GetFeatured() []Project {
+	featured := []Project{}
+	for _, project := range pc.Projects {
+		if project.Category == "featured" || project.Featured {
+			featured = append(featured, project)
+		}
+	}
+	return featured
+}
+
+// GetResumeProjects returns the projects listed on the resume
+func (pc *ProjectCatalog) GetResumeProjects() []Project {
+	projects := []Project{}
+	for _, project := range pc.Projects {
+		if project.Resume {
+			projects = append(projects, project)
+		}
+	}
+	return projects
+}
+
+// GetStatuses returns the distinct project statuses in catalog order
+func (pc *ProjectCatalog) GetStatuses() []ProjectFilterOption {
+	return pc.distinct(func(p Project) string { return p.Status })
+}
+
+// GetLanguages returns the distinct project languages in catalog order
+func (pc *ProjectCatalog) GetLanguages() []ProjectFilterOption {
+	return pc.distinct(func(p Project) string { return p.Language })
+}
+
+func (pc *ProjectCatalog) distinct(field func(Project) string) []ProjectFilterOption {
+	seen := make(map[string]bool)
+	options := []ProjectFilterOption{}
+	for _, project := range pc.Projects {
+		label := field(project)
+		value := slugify(label)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		options = append(options, ProjectFilterOption{Value: value, Label: label})
+	}
+	return options
+}
+
+func slugify(value string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(value)), " ", "-")
+}
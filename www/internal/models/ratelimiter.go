@@ -1,62 +1,44 @@
 package models
 
 import (
+	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type TokenBucket struct {
-	capacity    int
-	tokens      int
-	windowStart time.Time
-	windowSize  time.Duration
-	mutex       sync.Mutex
+// bucket is the limit state of one key in the memory backend
+type bucket struct {
+	state limitState
+	mutex sync.Mutex
 }
 
-func NewTokenBucket(capacity int, windowSize time.Duration) *TokenBucket {
-	return &TokenBucket{
-		capacity:    capacity,
-		tokens:      capacity,
-		windowStart: time.Now(),
-		windowSize:  windowSize,
-	}
-}
-
-func (tb *TokenBucket) Allow() bool {
-	tb.mutex.Lock()
-	defer tb.mutex.Unlock()
-
-	now := time.Now()
-
-	if now.Sub(tb.windowStart) >= tb.windowSize {
-		tb.windowStart = now
-		tb.tokens = tb.capacity
-	}
-
-	if tb.tokens > 0 {
-		tb.tokens--
-		return true
-	}
-	return false
-}
-
-func (tb *TokenBucket) GetTokens() int {
-	tb.mutex.Lock()
-	defer tb.mutex.Unlock()
-	return tb.tokens
+// bucketKey identifies a key under one policy
+type bucketKey struct {
+	policy string
+	key    string
 }
 
+// RateLimiter is the in-memory Limiter backend. Its counters are local to
+// the process.
 type RateLimiter struct {
-	buckets       map[string]*TokenBucket
+	buckets       map[bucketKey]*bucket
+	bans          map[string]time.Time
 	config        RateLimiterConfig
 	mutex         sync.RWMutex
 	cleanupTicker *time.Ticker
 	stopCleanup   chan bool
+	allowed       atomic.Uint64
+	rejected      atomic.Uint64
+	history       rejectionHistory
+	statePath     string
+	stopPersist   chan bool
 }
 
 func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
 	rl := &RateLimiter{
-		buckets:     make(map[string]*TokenBucket),
+		buckets:     make(map[bucketKey]*bucket),
+		bans:        make(map[string]time.Time),
 		config:      config,
 		stopCleanup: make(chan bool),
 	}
@@ -67,22 +49,149 @@ func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
 	return rl
 }
 
-func (rl *RateLimiter) Allow(userID string) bool {
+func (rl *RateLimiter) Allow(policyName, key string) (RateLimitResult, error) {
+	now := time.Now()
+
 	rl.mutex.Lock()
-	bucket, exists := rl.buckets[userID]
+	policy, err := rl.config.policy(policyName)
+	if err != nil {
+		rl.mutex.Unlock()
+		return RateLimitResult{}, err
+	}
+	if until, banned := rl.bans[key]; banned && until.After(now) {
+		rl.mutex.Unlock()
+		rl.reject(policyName, now)
+		return bannedResult(policy, until, now), nil
+	}
+	id := bucketKey{policy: policyName, key: key}
+	b, exists := rl.buckets[id]
 	if !exists {
-		bucket = NewTokenBucket(rl.config.BurstSize, rl.config.WindowSize)
-		rl.buckets[userID] = bucket
+		b = &bucket{}
+		rl.buckets[id] = b
 	}
 	rl.mutex.Unlock()
 
-	return bucket.Allow()
+	b.mutex.Lock()
+	result := policy.Algorithm.apply(&b.state, policy, now, true)
+	b.mutex.Unlock()
+
+	if result.Allowed {
+		rl.allowed.Add(1)
+	} else {
+		rl.reject(policyName, now)
+	}
+	return result, nil
+}
+
+func (rl *RateLimiter) reject(policy string, now time.Time) {
+	rl.rejected.Add(1)
+	rl.history.record(policy, now)
+}
+
+// Peek reports the budget of key, a full one if it was never seen
+func (rl *RateLimiter) Peek(policyName, key string) (RateLimitResult, error) {
+	now := time.Now()
+
+	rl.mutex.RLock()
+	policy, err := rl.config.policy(policyName)
+	b := rl.buckets[bucketKey{policy: policyName, key: key}]
+	until, banned := rl.bans[key]
+	rl.mutex.RUnlock()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	if banned && until.After(now) {
+		return bannedResult(policy, until, now), nil
+	}
+	return peek(b, policy, now), nil
+}
+
+// peek applies policy to a copy of the bucket's state, so it never changes
+// the stored state. A nil bucket has a full budget.
+func peek(b *bucket, policy RateLimitPolicy, now time.Time) RateLimitResult {
+	var state limitState
+	if b != nil {
+		b.mutex.Lock()
+		state = b.state
+		state.Log = append([]time.Time(nil), b.state.Log...)
+		b.mutex.Unlock()
+	}
+	return policy.Algorithm.apply(&state, policy, now, false)
+}
+
+func (rl *RateLimiter) Reset(policyName, key string) error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	delete(rl.buckets, bucketKey{policy: policyName, key: key})
+	return nil
+}
+
+func (rl *RateLimiter) Ban(key string, duration time.Duration) error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.bans[key] = time.Now().Add(duration)
+	return nil
 }
 
-func (rl *RateLimiter) GetBucket(userID string) *TokenBucket {
+func (rl *RateLimiter) Unban(key string) error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	delete(rl.bans, key)
+	return nil
+}
+
+func (rl *RateLimiter) Inspect() (Inspection, error) {
+	now := time.Now()
+	inspection := Inspection{Keys: []KeyState{}, Bans: []Ban{}}
+
 	rl.mutex.RLock()
 	defer rl.mutex.RUnlock()
-	return rl.buckets[userID]
+
+	for id, b := range rl.buckets {
+		policy, err := rl.config.policy(id.policy)
+		if err != nil {
+			continue
+		}
+
+		result := peek(b, policy, now)
+		if until, banned := rl.bans[id.key]; banned && until.After(now) {
+			result = bannedResult(policy, until, now)
+		}
+		inspection.Keys = append(inspection.Keys, KeyState{
+			Policy:    id.policy,
+			Key:       id.key,
+			Limit:     result.Limit,
+			Remaining: result.Remaining,
+			ResetAt:   result.ResetAt,
+			Throttled: !result.Allowed,
+		})
+	}
+	for key, until := range rl.bans {
+		if until.After(now) {
+			inspection.Bans = append(inspection.Bans, Ban{Key: key, Until: until})
+		}
+	}
+
+	inspection.sort()
+	return inspection, nil
+}
+
+func (rl *RateLimiter) Stats() (LimiterStats, error) {
+	return LimiterStats{
+		Backend:    "memory",
+		Keys:       rl.BucketCount(),
+		Allowed:    rl.allowed.Load(),
+		Rejected:   rl.rejected.Load(),
+		Rejections: rl.history.series(time.Now()),
+	}, nil
+}
+
+// BucketCount returns the number of keys currently tracked across policies
+func (rl *RateLimiter) BucketCount() int {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
+	return len(rl.buckets)
 }
 
 func (rl *RateLimiter) cleanup() {
@@ -91,9 +200,20 @@ func (rl *RateLimiter) cleanup() {
 		case <-rl.cleanupTicker.C:
 			rl.mutex.Lock()
 			now := time.Now()
-			for userID, bucket := range rl.buckets {
-				if now.Sub(bucket.windowStart) > time.Hour {
-					delete(rl.buckets, userID)
+			for id, b := range rl.buckets {
+				policy, err := rl.config.policy(id.policy)
+
+				b.mutex.Lock()
+				idle := err != nil || now.Sub(b.state.lastSeen()) > policy.forgetAfter()
+				b.mutex.Unlock()
+
+				if idle {
+					delete(rl.buckets, id)
+				}
+			}
+			for key, until := range rl.bans {
+				if !until.After(now) {
+					delete(rl.bans, key)
 				}
 			}
 			rl.mutex.Unlock()
@@ -104,10 +224,38 @@ func (rl *RateLimiter) cleanup() {
 	}
 }
 
+// Stop ends the background goroutines, saving the state a last time if it
+// is persisted
 func (rl *RateLimiter) Stop() {
 	rl.stopCleanup <- true
+
+	if rl.stopPersist != nil {
+		rl.stopPersist <- true
+		if err := rl.Save(rl.statePath); err != nil {
+			log.Printf("ratelimit: saving state: %v", err)
+		}
+	}
 }
 
 func (rl *RateLimiter) GetConfig() RateLimiterConfig {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
 	return rl.config
 }
+
+// SetConfig applies new limits to every key. Keys of a policy that was
+// removed or switched algorithms start over with a full budget.
+func (rl *RateLimiter) SetConfig(config RateLimiterConfig) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	for id := range rl.buckets {
+		old := rl.config.Policies[id.policy]
+		policy, ok := config.Policies[id.policy]
+		if !ok || policy.Algorithm != old.Algorithm {
+			delete(rl.buckets, id)
+		}
+	}
+	rl.config = config
+	rl.cleanupTicker.Reset(config.CleanupInterval)
+}
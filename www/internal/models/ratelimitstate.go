@@ -0,0 +1,190 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// rateLimitStateVersion is bumped whenever the state file format changes.
+// Files of another version are ignored rather than misread.
+const rateLimitStateVersion = 1
+
+// rateLimitStateFile is the on-disk form of the memory backend
+type rateLimitStateFile struct {
+	Version int           `json:"version"`
+	Saved   time.Time     `json:"saved"`
+	Buckets []savedBucket `json:"buckets"`
+	Bans    []Ban         `json:"bans"`
+}
+
+// savedBucket records the algorithm the state was built by, so a restart
+// with another algorithm starts the key over instead of misreading it
+type savedBucket struct {
+	Policy    string     `json:"policy"`
+	Key       string     `json:"key"`
+	Algorithm Algorithm  `json:"algorithm"`
+	State     limitState `json:"state"`
+}
+
+// Save writes every bucket and ban to path. The file is replaced atomically,
+// so a crash while saving leaves the previous snapshot intact.
+func (rl *RateLimiter) Save(path string) error {
+	file := rateLimitStateFile{
+		Version: rateLimitStateVersion,
+		Saved:   time.Now(),
+		Buckets: []savedBucket{},
+		Bans:    []Ban{},
+	}
+
+	rl.mutex.RLock()
+	for id, b := range rl.buckets {
+		policy, ok := rl.config.Policies[id.policy]
+		if !ok {
+			continue
+		}
+
+		b.mutex.Lock()
+		state := b.state
+		state.Log = append([]time.Time(nil), b.state.Log...)
+		b.mutex.Unlock()
+
+		file.Buckets = append(file.Buckets, savedBucket{
+			Policy:    id.policy,
+			Key:       id.key,
+			Algorithm: policy.Algorithm,
+			State:     state,
+		})
+	}
+	for key, until := range rl.bans {
+		if until.After(file.Saved) {
+			file.Bans = append(file.Bans, Ban{Key: key, Until: until})
+		}
+	}
+	rl.mutex.RUnlock()
+
+	content, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// Restore loads the state saved at path. Bans that have expired are skipped,
+// as are keys idle for longer than cleanup would keep them, keys of a policy
+// or algorithm that is no longer configured, and keys whose state could not
+// have been produced by now. A missing file is not an error, an unreadable
+// one is and leaves the limiter empty.
+func (rl *RateLimiter) Restore(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var file rateLimitStateFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if file.Version != rateLimitStateVersion {
+		return 0, fmt.Errorf("%s: unsupported version %d", path, file.Version)
+	}
+
+	now := time.Now()
+	restored := 0
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	for _, saved := range file.Buckets {
+		policy, ok := rl.config.Policies[saved.Policy]
+		if !ok || saved.Key == "" || saved.Algorithm != policy.Algorithm {
+			continue
+		}
+		if !saved.State.sanitize(policy, now) || now.Sub(saved.State.lastSeen()) > policy.forgetAfter() {
+			continue
+		}
+
+		rl.buckets[bucketKey{policy: saved.Policy, key: saved.Key}] = &bucket{state: saved.State}
+		restored++
+	}
+	for _, ban := range file.Bans {
+		if ban.Key != "" && ban.Until.After(now) {
+			rl.bans[ban.Key] = ban.Until
+		}
+	}
+	return restored, nil
+}
+
+// sanitize clamps a restored state to what the algorithm can produce. States
+// from the future, left by a clock step or an edited file, would keep a key
+// refused and out of reach of cleanup, so it reports false for those.
+func (s *limitState) sanitize(policy RateLimitPolicy, now time.Time) bool {
+	if s.WindowStart.After(now) || s.Updated.After(now) {
+		return false
+	}
+	for _, t := range s.Log {
+		if t.After(now) {
+			return false
+		}
+	}
+
+	// A GCRA arrival time runs ahead of now by at most one burst
+	if latest := now.Add(policy.window()); s.TAT.After(latest) {
+		s.TAT = latest
+	}
+	s.Count = min(max(s.Count, 0), policy.BurstSize)
+	s.Tokens = math.Min(math.Max(s.Tokens, 0), float64(policy.BurstSize))
+	if len(s.Log) > policy.BurstSize {
+		s.Log = s.Log[len(s.Log)-policy.BurstSize:]
+	}
+	return true
+}
+
+// Persist saves the state to path every interval, and once more on Stop
+func (rl *RateLimiter) Persist(path string, interval time.Duration) {
+	if rl.stopPersist != nil {
+		return
+	}
+
+	rl.statePath = path
+	rl.stopPersist = make(chan bool)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := rl.Save(path); err != nil {
+					log.Printf("ratelimit: saving state: %v", err)
+				}
+			case <-rl.stopPersist:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testLimiterConfig() RateLimiterConfig {
+	return RateLimiterConfig{
+		Policies: map[string]RateLimitPolicy{
+			DefaultPolicy: {Algorithm: TokenBucket, RequestsPerMinute: 60, BurstSize: 5, WindowSize: 15 * time.Second},
+			"window":      {Algorithm: FixedWindow, RequestsPerMinute: 60, BurstSize: 5, WindowSize: 15 * time.Second},
+			"log":         {Algorithm: SlidingWindowLog, RequestsPerMinute: 60, BurstSize: 5, WindowSize: 15 * time.Second},
+			"gcra":        {Algorithm: GCRA, RequestsPerMinute: 60, BurstSize: 5, WindowSize: 15 * time.Second},
+		},
+		CleanupInterval: time.Hour,
+	}
+}
+
+func newTestLimiter(t *testing.T) *RateLimiter {
+	t.Helper()
+	rl := NewRateLimiter(testLimiterConfig())
+	t.Cleanup(rl.Stop)
+	return rl
+}
+
+func writeStateFile(t *testing.T, file rateLimitStateFile) string {
+	t.Helper()
+	content, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeStateContent(t, content)
+}
+
+func writeStateContent(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRestoreMissingFile(t *testing.T) {
+	rl := newTestLimiter(t)
+
+	restored, err := rl.Restore(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || restored != 0 {
+		t.Fatalf("Restore of a missing file = %d, %v, want 0, nil", restored, err)
+	}
+}
+
+func TestRestoreCorruptFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"truncated", `{"version":1,"buckets":[{"policy":"default","key":"a"`},
+		{"not json", "ratelimit state"},
+		{"empty", ""},
+		{"wrong types", `{"version":"1","buckets":{}}`},
+		{"wrong version", `{"version":2,"buckets":[{"policy":"default","key":"a","algorithm":"token_bucket","state":{}}],"bans":[]}`},
+		{"no version", `{"buckets":[],"bans":[]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := newTestLimiter(t)
+
+			restored, err := rl.Restore(writeStateContent(t, []byte(tt.content)))
+			if err == nil {
+				t.Fatal("Restore succeeded, want an error")
+			}
+			if restored != 0 || rl.BucketCount() != 0 || len(rl.bans) != 0 {
+				t.Fatalf("Restore kept %d buckets and %d bans from a corrupt file", rl.BucketCount(), len(rl.bans))
+			}
+		})
+	}
+}
+
+func TestRestoreSkipsStaleEntries(t *testing.T) {
+	now := time.Now()
+	kept := limitState{Tokens: 1, Updated: now.Add(-time.Second)}
+
+	path := writeStateFile(t, rateLimitStateFile{
+		Version: rateLimitStateVersion,
+		Saved:   now,
+		Buckets: []savedBucket{
+			{Policy: DefaultPolicy, Key: "kept", Algorithm: TokenBucket, State: kept},
+			{Policy: "removed", Key: "unknown policy", Algorithm: TokenBucket, State: kept},
+			{Policy: DefaultPolicy, Key: "other algorithm", Algorithm: GCRA, State: limitState{TAT: now}},
+			{Policy: DefaultPolicy, Key: "", Algorithm: TokenBucket, State: kept},
+			{Policy: DefaultPolicy, Key: "idle", Algorithm: TokenBucket, State: limitState{Tokens: 1, Updated: now.Add(-2 * time.Hour)}},
+		},
+		Bans: []Ban{
+			{Key: "banned", Until: now.Add(time.Hour)},
+			{Key: "expired", Until: now.Add(-time.Minute)},
+			{Key: "", Until: now.Add(time.Hour)},
+		},
+	})
+
+	rl := newTestLimiter(t)
+	restored, err := rl.Restore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 1 || rl.BucketCount() != 1 {
+		t.Fatalf("restored %d keys into %d buckets, want only the kept key", restored, rl.BucketCount())
+	}
+	if _, ok := rl.buckets[bucketKey{policy: DefaultPolicy, key: "kept"}]; !ok {
+		t.Fatal("the kept key was not restored")
+	}
+	if len(rl.bans) != 1 {
+		t.Fatalf("restored bans %v, want only the active one", rl.bans)
+	}
+	if _, ok := rl.bans["banned"]; !ok {
+		t.Fatal("the active ban was not restored")
+	}
+}
+
+func TestRestoreSanitizesStates(t *testing.T) {
+	now := time.Now()
+
+	path := writeStateFile(t, rateLimitStateFile{
+		Version: rateLimitStateVersion,
+		Saved:   now,
+		Buckets: []savedBucket{
+			{Policy: "window", Key: "future window", Algorithm: FixedWindow, State: limitState{WindowStart: now.Add(time.Hour), Count: 5}},
+			{Policy: DefaultPolicy, Key: "future update", Algorithm: TokenBucket, State: limitState{Updated: now.Add(time.Hour)}},
+			{Policy: "log", Key: "future log", Algorithm: SlidingWindowLog, State: limitState{Log: []time.Time{now.Add(time.Hour)}}},
+			{Policy: "gcra", Key: "far tat", Algorithm: GCRA, State: limitState{TAT: now.Add(24 * time.Hour)}},
+			{Policy: DefaultPolicy, Key: "overfull", Algorithm: TokenBucket, State: limitState{Tokens: 500, Updated: now.Add(-time.Second)}},
+			{Policy: "window", Key: "overcounted", Algorithm: FixedWindow, State: limitState{WindowStart: now.Add(-time.Second), Count: 500}},
+			{Policy: "log", Key: "long log", Algorithm: SlidingWindowLog, State: limitState{Log: []time.Time{
+				now.Add(-7 * time.Second), now.Add(-6 * time.Second), now.Add(-5 * time.Second),
+				now.Add(-4 * time.Second), now.Add(-3 * time.Second), now.Add(-2 * time.Second), now.Add(-time.Second),
+			}}},
+		},
+		Bans: []Ban{},
+	})
+
+	rl := newTestLimiter(t)
+	restored, err := rl.Restore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 4 {
+		t.Fatalf("restored %d keys, want 4 without the future states", restored)
+	}
+
+	for _, key := range []string{"future window", "future update", "future log"} {
+		for id := range rl.buckets {
+			if id.key == key {
+				t.Errorf("state %q from the future was restored", key)
+			}
+		}
+	}
+
+	gcra := rl.buckets[bucketKey{policy: "gcra", key: "far tat"}]
+	if limit := time.Now().Add(rl.config.Policies["gcra"].window()); gcra == nil || gcra.state.TAT.After(limit) {
+		t.Errorf("GCRA arrival time was not clamped to one burst ahead")
+	}
+	if b := rl.buckets[bucketKey{policy: DefaultPolicy, key: "overfull"}]; b == nil || b.state.Tokens != 5 {
+		t.Errorf("tokens were not clamped to the burst size")
+	}
+	if b := rl.buckets[bucketKey{policy: "window", key: "overcounted"}]; b == nil || b.state.Count != 5 {
+		t.Errorf("window count was not clamped to the burst size")
+	}
+	if b := rl.buckets[bucketKey{policy: "log", key: "long log"}]; b == nil || len(b.state.Log) != 5 || !b.state.Log[4].Equal(now.Add(-time.Second)) {
+		t.Errorf("log was not cut to the newest burst size entries")
+	}
+
+	// A clamped GCRA key is refused for at most one burst, not a day
+	result, err := rl.Peek("gcra", "far tat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RetryAfter > 5*time.Second {
+		t.Errorf("GCRA key refused for %s after clamping", result.RetryAfter)
+	}
+}
+
+func TestSaveRestoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+
+	rl := newTestLimiter(t)
+	for _, policy := range []string{DefaultPolicy, "window", "log", "gcra"} {
+		for range 3 {
+			if _, err := rl.Allow(policy, "client"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := rl.Ban("abuser", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, _ := filepath.Glob(path + ".*.tmp")
+	if len(matches) > 0 {
+		t.Errorf("Save left temporary files %v", matches)
+	}
+
+	restoredLimiter := newTestLimiter(t)
+	restored, err := restoredLimiter.Restore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 4 {
+		t.Fatalf("restored %d keys, want 4", restored)
+	}
+
+	for _, policy := range []string{DefaultPolicy, "window", "log", "gcra"} {
+		result, err := restoredLimiter.Peek(policy, "client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Remaining != 2 {
+			t.Errorf("%s: %d requests remaining after restore, want 2", policy, result.Remaining)
+		}
+	}
+
+	result, err := restoredLimiter.Allow(DefaultPolicy, "abuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || !result.Banned {
+		t.Error("the ban was not restored")
+	}
+}
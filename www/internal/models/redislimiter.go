@@ -0,0 +1,353 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x800a6/www/internal/redis"
+)
+
+// Each script applies one algorithm to KEYS[1], mirroring the memory backend,
+// after checking the ban key KEYS[2]. ARGV is now in ms, consume (1 or 0),
+// limit, window in ms, ms per token and a unique member for the sliding log.
+// They all return allowed (1, 0 or -1 when banned), remaining, the full reset
+// time in ms and the retry delay in ms.
+var redisScripts = map[Algorithm]string{}
+
+// redisBanCheck is run before every algorithm
+const redisBanCheck = `
+local banned = redis.call('PTTL', KEYS[2])
+if banned > 0 then
+	return {-1, 0, tonumber(ARGV[1]) + banned, banned}
+end
+`
+
+func init() {
+	for algorithm, script := range redisAlgorithms {
+		redisScripts[algorithm] = redisBanCheck + script
+	}
+}
+
+var redisAlgorithms = map[Algorithm]string{
+	FixedWindow: `
+local now, consume, limit, window = tonumber(ARGV[1]), ARGV[2] == '1', tonumber(ARGV[3]), tonumber(ARGV[4])
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	redis.call('DEL', KEYS[1])
+	count, ttl = 0, window
+end
+local allowed = count < limit
+if allowed and consume then
+	count = redis.call('INCR', KEYS[1])
+	if count == 1 then
+		redis.call('PEXPIRE', KEYS[1], window)
+	end
+end
+local retry = 0
+if not allowed then
+	retry = ttl
+end
+return {allowed and 1 or 0, math.max(limit - count, 0), now + ttl, retry}
+`,
+	TokenBucket: `
+local now, consume, limit, interval = tonumber(ARGV[1]), ARGV[2] == '1', tonumber(ARGV[3]), tonumber(ARGV[5])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens, updated = tonumber(state[1]), tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens, updated = limit, now
+end
+if now > updated then
+	tokens = math.min(limit, tokens + (now - updated) / interval)
+	updated = now
+end
+local allowed = tokens >= 1
+if allowed and consume then
+	tokens = tokens - 1
+end
+local full = math.ceil((limit - tokens) * interval)
+if consume then
+	redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(updated))
+	redis.call('PEXPIRE', KEYS[1], full + math.ceil(interval))
+end
+local retry = 0
+if not allowed then
+	retry = math.ceil((1 - tokens) * interval)
+end
+return {allowed and 1 or 0, math.floor(tokens), now + full, retry}
+`,
+	SlidingWindowLog: `
+local now, consume, limit, window = tonumber(ARGV[1]), ARGV[2] == '1', tonumber(ARGV[3]), tonumber(ARGV[4])
+if consume then
+	redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+end
+local count = redis.call('ZCOUNT', KEYS[1], '(' .. (now - window), '+inf')
+local allowed = count < limit
+if allowed and consume then
+	redis.call('ZADD', KEYS[1], now, ARGV[6])
+	redis.call('PEXPIRE', KEYS[1], window)
+	count = count + 1
+end
+local reset, retry = now, 0
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if #newest > 0 then
+	reset = tonumber(newest[2]) + window
+end
+if not allowed then
+	local oldest = redis.call('ZRANGEBYSCORE', KEYS[1], '(' .. (now - window), '+inf', 'WITHSCORES', 'LIMIT', 0, 1)
+	retry = tonumber(oldest[2]) + window - now
+end
+return {allowed and 1 or 0, math.max(limit - count, 0), reset, retry}
+`,
+	GCRA: `
+local now, consume, limit, interval = tonumber(ARGV[1]), ARGV[2] == '1', tonumber(ARGV[3]), tonumber(ARGV[5])
+local burst = interval * limit
+local tat = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tat < now then
+	tat = now
+end
+local allowAt = tat + interval - burst
+local allowed = now >= allowAt
+if allowed and consume then
+	tat = tat + interval
+	redis.call('SET', KEYS[1], tostring(tat), 'PX', math.ceil(tat - now) + 1)
+end
+local retry = 0
+if not allowed then
+	retry = math.ceil(allowAt - now)
+end
+return {allowed and 1 or 0, math.max(math.floor((burst - (tat - now)) / interval), 0), math.ceil(tat), retry}
+`,
+}
+
+// RedisLimiter is a Limiter backend keeping its windows in a Redis-compatible
+// server, so every instance behind the proxy shares the same limits
+type RedisLimiter struct {
+	client   *redis.Client
+	prefix   string
+	config   RateLimiterConfig
+	mutex    sync.RWMutex
+	allowed  atomic.Uint64
+	rejected atomic.Uint64
+	sequence atomic.Uint64
+	history  rejectionHistory
+}
+
+func NewRedisLimiter(client *redis.Client, prefix string, config RateLimiterConfig) *RedisLimiter {
+	return &RedisLimiter{
+		client: client,
+		prefix: prefix,
+		config: config,
+	}
+}
+
+func (rl *RedisLimiter) Allow(policy, key string) (RateLimitResult, error) {
+	result, err := rl.run(policy, key, true)
+	if err != nil {
+		return result, err
+	}
+
+	if result.Allowed {
+		rl.allowed.Add(1)
+	} else {
+		rl.rejected.Add(1)
+		rl.history.record(policy, time.Now())
+	}
+	return result, nil
+}
+
+func (rl *RedisLimiter) Peek(policy, key string) (RateLimitResult, error) {
+	return rl.run(policy, key, false)
+}
+
+// run evaluates the script of the policy's algorithm. The time comes from
+// this process so both backends agree on it.
+func (rl *RedisLimiter) run(policyName, key string, consume bool) (RateLimitResult, error) {
+	policy, err := rl.GetConfig().policy(policyName)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	now := time.Now()
+
+	flag := 0
+	if consume {
+		flag = 1
+	}
+	interval := float64(perToken(policy)) / float64(time.Millisecond)
+	member := strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatUint(rl.sequence.Add(1), 36)
+
+	values, err := redis.Values(rl.client.Do("EVAL", redisScripts[policy.Algorithm], 2, rl.key(policyName, policy, key),
+		rl.banKey(key), now.UnixMilli(), flag, policy.BurstSize, policy.WindowSize.Milliseconds(), interval, member))
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(values) != 4 {
+		return RateLimitResult{}, fmt.Errorf("ratelimit: unexpected script reply %v", values)
+	}
+
+	numbers := make([]int64, len(values))
+	for i, value := range values {
+		if numbers[i], err = redis.Int(value, nil); err != nil {
+			return RateLimitResult{}, err
+		}
+	}
+
+	result := RateLimitResult{
+		Allowed:    numbers[0] == 1,
+		Banned:     numbers[0] == -1,
+		Limit:      policy.BurstSize,
+		Remaining:  int(numbers[1]),
+		ResetAt:    time.UnixMilli(numbers[2]),
+		RetryAfter: time.Duration(numbers[3]) * time.Millisecond,
+		Window:     policy.window(),
+	}
+	if result.ResetAt.Before(now) {
+		result.ResetAt = now
+	}
+	return result, nil
+}
+
+func (rl *RedisLimiter) Reset(policyName, key string) error {
+	policy, err := rl.GetConfig().policy(policyName)
+	if err != nil {
+		return err
+	}
+	_, err = rl.client.Do("DEL", rl.key(policyName, policy, key))
+	return err
+}
+
+func (rl *RedisLimiter) Ban(key string, duration time.Duration) error {
+	_, err := rl.client.Do("SET", rl.banKey(key), 1, "PX", max(duration.Milliseconds(), 1))
+	return err
+}
+
+func (rl *RedisLimiter) Unban(key string) error {
+	_, err := rl.client.Do("DEL", rl.banKey(key))
+	return err
+}
+
+// Inspect peeks at every key under the prefix, so it costs a round trip per
+// key and is meant for the admin pages only
+func (rl *RedisLimiter) Inspect() (Inspection, error) {
+	inspection := Inspection{Keys: []KeyState{}, Bans: []Ban{}}
+	config := rl.GetConfig()
+
+	names, err := rl.scan()
+	if err != nil {
+		return inspection, err
+	}
+
+	for _, name := range names {
+		name = strings.TrimPrefix(name, rl.prefix)
+
+		if key, ok := strings.CutPrefix(name, banPrefix); ok {
+			ttl, err := redis.Int(rl.client.Do("PTTL", rl.banKey(key)))
+			if err != nil {
+				return inspection, err
+			}
+			if ttl > 0 {
+				inspection.Bans = append(inspection.Bans, Ban{Key: key, Until: time.Now().Add(time.Duration(ttl) * time.Millisecond)})
+			}
+			continue
+		}
+
+		// Keys left behind by another algorithm expire on their own
+		parts := strings.SplitN(name, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		policy, ok := config.Policies[parts[0]]
+		if !ok || string(policy.Algorithm) != parts[1] {
+			continue
+		}
+
+		result, err := rl.Peek(parts[0], parts[2])
+		if err != nil {
+			return inspection, err
+		}
+		inspection.Keys = append(inspection.Keys, KeyState{
+			Policy:    parts[0],
+			Key:       parts[2],
+			Limit:     result.Limit,
+			Remaining: result.Remaining,
+			ResetAt:   result.ResetAt,
+			Throttled: !result.Allowed,
+		})
+	}
+
+	inspection.sort()
+	return inspection, nil
+}
+
+// Stats counts the keys under the prefix with SCAN, so it is meant for
+// dashboards and scrapes rather than the request path
+func (rl *RedisLimiter) Stats() (LimiterStats, error) {
+	stats := LimiterStats{
+		Backend:    "redis",
+		Allowed:    rl.allowed.Load(),
+		Rejected:   rl.rejected.Load(),
+		Rejections: rl.history.series(time.Now()),
+	}
+
+	names, err := rl.scan()
+	stats.Keys = len(names)
+	return stats, err
+}
+
+// scan returns the names of every key under the prefix
+func (rl *RedisLimiter) scan() ([]string, error) {
+	names := []string{}
+	cursor := "0"
+	for {
+		values, err := redis.Values(rl.client.Do("SCAN", cursor, "MATCH", rl.prefix+"*", "COUNT", 1000))
+		if err != nil {
+			return names, err
+		}
+
+		cursor, _ = values[0].(string)
+		keys, _ := values[1].([]interface{})
+		for _, key := range keys {
+			if name, ok := key.(string); ok {
+				names = append(names, name)
+			}
+		}
+
+		if cursor == "0" || cursor == "" {
+			return names, nil
+		}
+	}
+}
+
+func (rl *RedisLimiter) GetConfig() RateLimiterConfig {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
+	return rl.config
+}
+
+// SetConfig applies new limits. The state of each algorithm is stored
+// differently, so switching a policy's algorithm changes its prefix and
+// starts every key over.
+func (rl *RedisLimiter) SetConfig(config RateLimiterConfig) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.config = config
+}
+
+func (rl *RedisLimiter) key(policyName string, policy RateLimitPolicy, key string) string {
+	return rl.prefix + policyName + ":" + string(policy.Algorithm) + ":" + key
+}
+
+// banPrefix cannot clash with policy keys, policy names are [a-z0-9_-]
+const banPrefix = "!ban:"
+
+func (rl *RedisLimiter) banKey(key string) string {
+	return rl.prefix + banPrefix + key
+}
+
+func (rl *RedisLimiter) Stop() {
+	rl.client.Close()
+}
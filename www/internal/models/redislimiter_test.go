@@ -0,0 +1,74 @@
+package models
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/0x800a6/www/internal/redis"
+)
+
+// newTestRedisLimiter returns a limiter on the server at url, keeping to keys
+// under a prefix of its own and deleting them when the test ends
+func newTestRedisLimiter(t *testing.T, url string) *RedisLimiter {
+	t.Helper()
+	client, err := redis.NewClient(url, 4, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "www-test:" + strconv.FormatInt(time.Now().UnixNano(), 36) + ":"
+	limiter := NewRedisLimiter(client, prefix, testLimiterConfig())
+
+	t.Cleanup(func() {
+		names, _ := limiter.scan()
+		for _, name := range names {
+			client.Do("DEL", name)
+		}
+		limiter.Stop()
+	})
+	return limiter
+}
+
+func testRedisLimiter(t *testing.T, limiter *RedisLimiter) {
+	testLimiterBackend(t, limiter)
+
+	stats, err := limiter.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Backend != "redis" || stats.Keys == 0 || stats.Allowed == 0 || stats.Rejected == 0 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+// TestRedisLimiter runs the scripts against miniredis, an in-process server
+// with a Lua interpreter
+func TestRedisLimiter(t *testing.T) {
+	server := miniredis.RunT(t)
+	limiter := newTestRedisLimiter(t, "redis://"+server.Addr())
+
+	testRedisLimiter(t, limiter)
+
+	// Bans and windows live in key TTLs, which miniredis only counts down
+	// when told to
+	if err := limiter.Ban("expiring", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(2 * time.Second)
+	if result, _ := limiter.Allow(DefaultPolicy, "expiring"); !result.Allowed {
+		t.Error("expired ban still refuses the key")
+	}
+}
+
+// TestRedisLimiterServer repeats the test against a real server when
+// WWW_TEST_REDIS_URL names one
+func TestRedisLimiterServer(t *testing.T) {
+	url := os.Getenv("WWW_TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("WWW_TEST_REDIS_URL is not set")
+	}
+	testRedisLimiter(t, newTestRedisLimiter(t, url))
+}
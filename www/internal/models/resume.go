@@ -0,0 +1,627 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/0x800a6/www/internal/utils"
+)
+
+// Resume represents a resume following the JSON Resume schema
+// (https://jsonresume.org/schema). Fields marked as extensions are additional
+// properties the schema allows, used only for rendering the resume page.
+type Resume struct {
+	Schema       string              `json:"$schema,omitempty"`
+	Basics       ResumeBasics        `json:"basics"`
+	Work         []ResumeWork        `json:"work,omitempty"`
+	Volunteer    []ResumeVolunteer   `json:"volunteer,omitempty"`
+	Education    []ResumeEducation   `json:"education,omitempty"`
+	Awards       []ResumeAward       `json:"awards,omitempty"`
+	Certificates []ResumeCertificate `json:"certificates,omitempty"`
+	Publications []ResumePublication `json:"publications,omitempty"`
+	Skills       []ResumeSkill       `json:"skills,omitempty"`
+	Languages    []ResumeLanguage    `json:"languages,omitempty"`
+	Interests    []ResumeInterest    `json:"interests,omitempty"`
+	References   []ResumeReference   `json:"references,omitempty"`
+	Projects     []ResumeProject     `json:"projects,omitempty"`
+	Meta         ResumeMeta          `json:"meta,omitzero"`
+}
+
+type ResumeBasics struct {
+	Name     string          `json:"name"`
+	Label    string          `json:"label,omitempty"`
+	Image    string          `json:"image,omitempty"`
+	Email    string          `json:"email,omitempty"`
+	Phone    string          `json:"phone,omitempty"`
+	URL      string          `json:"url,omitempty"`
+	Summary  string          `json:"summary,omitempty"`
+	Location ResumeLocation  `json:"location,omitzero"`
+	Profiles []ResumeProfile `json:"profiles,omitempty"`
+}
+
+type ResumeLocation struct {
+	Address     string `json:"address,omitempty"`
+	PostalCode  string `json:"postalCode,omitempty"`
+	City        string `json:"city,omitempty"`
+	CountryCode string `json:"countryCode,omitempty"`
+	Region      string `json:"region,omitempty"`
+}
+
+type ResumeProfile struct {
+	Network  string `json:"network"`
+	Username string `json:"username,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+type ResumeWork struct {
+	Name       string   `json:"name"`
+	Position   string   `json:"position,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	StartDate  string   `json:"startDate,omitempty"`
+	EndDate    string   `json:"endDate,omitempty"`
+	Summary    string   `json:"summary,omitempty"`
+	Highlights []string `json:"highlights,omitempty"`
+}
+
+type ResumeVolunteer struct {
+	Organization string   `json:"organization"`
+	Position     string   `json:"position,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	StartDate    string   `json:"startDate,omitempty"`
+	EndDate      string   `json:"endDate,omitempty"`
+	Summary      string   `json:"summary,omitempty"`
+	Highlights   []string `json:"highlights,omitempty"`
+}
+
+type ResumeEducation struct {
+	Institution string   `json:"institution"`
+	URL         string   `json:"url,omitempty"`
+	Area        string   `json:"area,omitempty"`
+	StudyType   string   `json:"studyType,omitempty"`
+	StartDate   string   `json:"startDate,omitempty"`
+	EndDate     string   `json:"endDate,omitempty"`
+	Score       string   `json:"score,omitempty"`
+	Courses     []string `json:"courses,omitempty"`
+}
+
+type ResumeAward struct {
+	Title   string `json:"title"`
+	Date    string `json:"date,omitempty"`
+	Awarder string `json:"awarder,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+type ResumeCertificate struct {
+	Name   string `json:"name"`
+	Date   string `json:"date,omitempty"`
+	Issuer string `json:"issuer,omitempty"`
+	URL    string `json:"url,omitempty"`
+}
+
+type ResumePublication struct {
+	Name        string `json:"name"`
+	Publisher   string `json:"publisher,omitempty"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+}
+
+// ResumeSkill represents a skill group. Primary is an extension listing the
+// keywords highlighted on the resume page.
+type ResumeSkill struct {
+	Name     string   `json:"name"`
+	Level    string   `json:"level,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Primary  []string `json:"primary,omitempty"`
+}
+
+// ResumeSkillTag represents a single keyword of a skill group for rendering
+type ResumeSkillTag struct {
+	Name    string
+	Primary bool
+}
+
+type ResumeLanguage struct {
+	Language string `json:"language"`
+	Fluency  string `json:"fluency,omitempty"`
+}
+
+// ResumeInterest represents an interest. Summary and Icon are extensions.
+type ResumeInterest struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords,omitempty"`
+	Summary  string   `json:"summary,omitempty"`
+	Icon     string   `json:"icon,omitempty"`
+}
+
+type ResumeReference struct {
+	Name      string `json:"name"`
+	Reference string `json:"reference,omitempty"`
+}
+
+// ResumeProject represents a project. Status, Featured and Icon are extensions.
+type ResumeProject struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Highlights  []string `json:"highlights,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	StartDate   string   `json:"startDate,omitempty"`
+	EndDate     string   `json:"endDate,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Entity      string   `json:"entity,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Status      string   `json:"status,omitempty"`
+	Featured    bool     `json:"featured,omitempty"`
+	Icon        string   `json:"icon,omitempty"`
+}
+
+type ResumeMeta struct {
+	Canonical    string `json:"canonical,omitempty"`
+	Version      string `json:"version,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// ResumeNamePart represents one word of the name with its CSS class
+type ResumeNamePart struct {
+	Class string
+	Text  string
+}
+
+// LoadResume reads a JSON Resume document
+func LoadResume(path string) (*Resume, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resume := &Resume{}
+	if err := json.Unmarshal(content, resume); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	if resume.Basics.Name == "" {
+		return nil, fmt.Errorf("parsing %s: basics.name is required", path)
+	}
+
+	info, err := os.Stat(path)
+	if err == nil && resume.Meta.LastModified == "" {
+		resume.Meta.LastModified = info.ModTime().UTC().Format("2006-01-02T15:04:05")
+	}
+
+	return resume, nil
+}
+
+// ToResumeProject converts a catalog project into a JSON Resume project
+func (p Project) ToResumeProject() ResumeProject {
+	project := ResumeProject{
+		Name:        p.Name,
+		Description: p.Description,
+		Keywords:    p.Tech,
+		Type:        "application",
+		Status:      p.Status,
+		Featured:    p.Featured,
+		Icon:        p.Icon,
+	}
+
+	for _, link := range p.Links {
+		if link.URL != "" {
+			project.URL = link.URL
+			break
+		}
+	}
+
+	return project
+}
+
+// WithProjects returns a copy of the resume with the given projects listed
+// ahead of the ones declared in the resume file
+func (r *Resume) WithProjects(projects []Project) *Resume {
+	resume := *r
+	resume.Projects = make([]ResumeProject, 0, len(projects)+len(r.Projects))
+	for _, project := range projects {
+		resume.Projects = append(resume.Projects, project.ToResumeProject())
+	}
+	resume.Projects = append(resume.Projects, r.Projects...)
+	return &resume
+}
+
+// GetProjectsByType returns the projects with the given type
+func (r *Resume) GetProjectsByType(projectType string) []ResumeProject {
+	projects := []ResumeProject{}
+	for _, project := range r.Projects {
+		if project.Type == projectType {
+			projects = append(projects, project)
+		}
+	}
+	return projects
+}
+
+// NameParts splits the name into first, middle and last parts
+func (r *Resume) NameParts() []ResumeNamePart {
+	words := strings.Fields(r.Basics.Name)
+	parts := make([]ResumeNamePart, len(words))
+	for i, word := range words {
+		class := "name-middle"
+		if i == 0 {
+			class = "name-first"
+		} else if i == len(words)-1 {
+			class = "name-last"
+		}
+		parts[i] = ResumeNamePart{Class: class, Text: word}
+	}
+	return parts
+}
+
+// Tags returns the skill keywords with primary ones flagged
+func (s ResumeSkill) Tags() []ResumeSkillTag {
+	primary := make(map[string]bool, len(s.Primary))
+	for _, keyword := range s.Primary {
+		primary[keyword] = true
+	}
+
+	tags := make([]ResumeSkillTag, len(s.Keywords))
+	for i, keyword := range s.Keywords {
+		tags[i] = ResumeSkillTag{Name: keyword, Primary: primary[keyword]}
+	}
+	return tags
+}
+
+// Icon returns the Bootstrap icon class for the profile network
+func (p ResumeProfile) Icon() string {
+	switch strings.ToLower(p.Network) {
+	case "x", "twitter":
+		return "bi-twitter-x"
+	case "github", "mastodon", "discord", "linkedin", "youtube", "twitch":
+		return "bi-" + strings.ToLower(p.Network)
+	default:
+		return "bi-link-45deg"
+	}
+}
+
+// String formats the location for display
+func (l ResumeLocation) String() string {
+	parts := []string{}
+	for _, part := range []string{l.City, l.Region, countryName(l.CountryCode)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func countryName(code string) string {
+	switch strings.ToUpper(code) {
+	case "US":
+		return "United States"
+	case "GB":
+		return "United Kingdom"
+	case "CA":
+		return "Canada"
+	default:
+		return code
+	}
+}
+
+// ResumeSection is a resume section reduced to entries every export format
+// can render the same way
+type ResumeSection struct {
+	Title   string
+	Icon    string
+	Entries []ResumeEntry
+}
+
+// ResumeEntry is one item of a ResumeSection
+type ResumeEntry struct {
+	Title    string
+	Subtitle string
+	Date     string
+	URL      string
+	Summary  string
+	Items    []string
+}
+
+// Sections returns the non-empty sections of the resume other than the
+// basics, skills, projects and interests, which every format renders in its
+// own way
+func (r *Resume) Sections() []ResumeSection {
+	sections := []ResumeSection{}
+	add := func(title, icon string, entries []ResumeEntry) {
+		if len(entries) > 0 {
+			sections = append(sections, ResumeSection{Title: title, Icon: icon, Entries: entries})
+		}
+	}
+
+	var entries []ResumeEntry
+	for _, work := range r.Work {
+		entries = append(entries, ResumeEntry{
+			Title:   joinNonEmpty(", ", work.Position, work.Name),
+			Date:    dateRange(work.StartDate, work.EndDate),
+			URL:     work.URL,
+			Summary: work.Summary,
+			Items:   work.Highlights,
+		})
+	}
+	add("Experience", "bi-briefcase", entries)
+
+	entries = nil
+	for _, education := range r.Education {
+		entry := ResumeEntry{
+			Title: joinNonEmpty(", ", joinNonEmpty(" in ", education.StudyType, education.Area), education.Institution),
+			Date:  dateRange(education.StartDate, education.EndDate),
+			URL:   education.URL,
+			Items: education.Courses,
+		}
+		if education.Score != "" {
+			entry.Subtitle = "Score: " + education.Score
+		}
+		entries = append(entries, entry)
+	}
+	add("Education", "bi-mortarboard", entries)
+
+	entries = nil
+	for _, volunteer := range r.Volunteer {
+		entries = append(entries, ResumeEntry{
+			Title:   joinNonEmpty(", ", volunteer.Position, volunteer.Organization),
+			Date:    dateRange(volunteer.StartDate, volunteer.EndDate),
+			URL:     volunteer.URL,
+			Summary: volunteer.Summary,
+			Items:   volunteer.Highlights,
+		})
+	}
+	add("Volunteering", "bi-people", entries)
+
+	entries = nil
+	for _, award := range r.Awards {
+		entries = append(entries, ResumeEntry{Title: award.Title, Subtitle: award.Awarder, Date: award.Date, Summary: award.Summary})
+	}
+	add("Awards", "bi-trophy", entries)
+
+	entries = nil
+	for _, certificate := range r.Certificates {
+		entries = append(entries, ResumeEntry{Title: certificate.Name, Subtitle: certificate.Issuer, Date: certificate.Date, URL: certificate.URL})
+	}
+	add("Certificates", "bi-patch-check", entries)
+
+	entries = nil
+	for _, publication := range r.Publications {
+		entries = append(entries, ResumeEntry{Title: publication.Name, Subtitle: publication.Publisher, Date: publication.ReleaseDate, URL: publication.URL, Summary: publication.Summary})
+	}
+	add("Publications", "bi-journal-text", entries)
+
+	entries = nil
+	for _, language := range r.Languages {
+		entries = append(entries, ResumeEntry{Title: language.Language, Subtitle: language.Fluency})
+	}
+	add("Languages", "bi-translate", entries)
+
+	entries = nil
+	for _, reference := range r.References {
+		entries = append(entries, ResumeEntry{Title: reference.Name, Summary: reference.Reference})
+	}
+	add("References", "bi-chat-quote", entries)
+
+	return sections
+}
+
+// Meta joins the subtitle and date of the entry for single line formats
+func (e ResumeEntry) Meta() string {
+	return joinNonEmpty(" | ", e.Subtitle, e.Date)
+}
+
+// dateRange formats JSON Resume dates, an entry without an end date being
+// current
+func dateRange(start, end string) string {
+	switch {
+	case start == "":
+		return end
+	case end == "":
+		return start + " - Present"
+	}
+	return start + " - " + end
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	kept := []string{}
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// PlainText renders the resume as plain text wrapped at width columns
+func (r *Resume) PlainText(width int) string {
+	var text strings.Builder
+
+	writeHeading := func(title string) {
+		text.WriteString("\n" + strings.ToUpper(title) + "\n")
+		text.WriteString(strings.Repeat("=", utils.Min(len(title), width)) + "\n\n")
+	}
+	writeParagraph := func(paragraph, indent string) {
+		for _, line := range utils.WrapText(paragraph, width, indent) {
+			text.WriteString(line + "\n")
+		}
+	}
+
+	text.WriteString(r.Basics.Name + "\n")
+	if r.Basics.Label != "" {
+		text.WriteString(r.Basics.Label + "\n")
+	}
+	text.WriteString("\n")
+	for _, contact := range r.contactLines() {
+		writeParagraph(contact, "")
+	}
+
+	if r.Basics.Summary != "" {
+		writeHeading("About Me")
+		writeParagraph(r.Basics.Summary, "")
+	}
+
+	for _, section := range r.Sections() {
+		writeHeading(section.Title)
+		for i, entry := range section.Entries {
+			if i > 0 {
+				text.WriteString("\n")
+			}
+			text.WriteString("* " + entry.Title + "\n")
+			if meta := entry.Meta(); meta != "" {
+				writeParagraph(meta, "  ")
+			}
+			if entry.Summary != "" {
+				writeParagraph(entry.Summary, "  ")
+			}
+			for _, item := range entry.Items {
+				writeParagraph("- "+item, "  ")
+			}
+			if entry.URL != "" {
+				writeParagraph(entry.URL, "  ")
+			}
+		}
+	}
+
+	if len(r.Skills) > 0 {
+		writeHeading("Technical Skills")
+		for _, skill := range r.Skills {
+			writeParagraph(skill.Name+": "+strings.Join(skill.Keywords, ", "), "  ")
+		}
+	}
+
+	if len(r.Projects) > 0 {
+		writeHeading("Projects")
+		for i, project := range r.Projects {
+			if i > 0 {
+				text.WriteString("\n")
+			}
+			title := "* " + project.Name
+			if project.Status != "" {
+				title += " (" + project.Status + ")"
+			}
+			text.WriteString(title + "\n")
+			if project.Description != "" {
+				writeParagraph(project.Description, "  ")
+			}
+			if len(project.Keywords) > 0 {
+				writeParagraph("Tech: "+strings.Join(project.Keywords, ", "), "  ")
+			}
+			if project.URL != "" {
+				writeParagraph(project.URL, "  ")
+			}
+		}
+	}
+
+	if len(r.Interests) > 0 {
+		writeHeading("Interests")
+		for _, interest := range r.Interests {
+			text.WriteString("* " + interest.Name + "\n")
+			if interest.Summary != "" {
+				writeParagraph(interest.Summary, "  ")
+			}
+		}
+	}
+
+	return text.String()
+}
+
+// Markdown renders the resume as a Markdown document
+func (r *Resume) Markdown() string {
+	var md strings.Builder
+
+	md.WriteString("# " + r.Basics.Name + "\n\n")
+	if r.Basics.Label != "" {
+		md.WriteString("**" + r.Basics.Label + "**\n\n")
+	}
+	for _, contact := range r.contactLines() {
+		md.WriteString("- " + contact + "\n")
+	}
+
+	if r.Basics.Summary != "" {
+		md.WriteString("\n## About Me\n\n" + r.Basics.Summary + "\n")
+	}
+
+	for _, section := range r.Sections() {
+		md.WriteString("\n## " + section.Title + "\n")
+		for _, entry := range section.Entries {
+			title := entry.Title
+			if entry.URL != "" {
+				title = "[" + entry.Title + "](" + entry.URL + ")"
+			}
+			md.WriteString("\n### " + title + "\n")
+			if meta := entry.Meta(); meta != "" {
+				md.WriteString("\n*" + meta + "*\n")
+			}
+			if entry.Summary != "" {
+				md.WriteString("\n" + entry.Summary + "\n")
+			}
+			if len(entry.Items) > 0 {
+				md.WriteString("\n")
+				for _, item := range entry.Items {
+					md.WriteString("- " + item + "\n")
+				}
+			}
+		}
+	}
+
+	if len(r.Skills) > 0 {
+		md.WriteString("\n## Technical Skills\n\n")
+		for _, skill := range r.Skills {
+			md.WriteString("- **" + skill.Name + "**: " + strings.Join(skill.Keywords, ", ") + "\n")
+		}
+	}
+
+	if len(r.Projects) > 0 {
+		md.WriteString("\n## Projects\n")
+		for _, project := range r.Projects {
+			name := project.Name
+			if project.URL != "" {
+				name = "[" + project.Name + "](" + project.URL + ")"
+			}
+			md.WriteString("\n### " + name + "\n\n")
+			if project.Status != "" {
+				md.WriteString("*" + project.Status + "*\n\n")
+			}
+			if project.Description != "" {
+				md.WriteString(project.Description + "\n")
+			}
+			if len(project.Keywords) > 0 {
+				md.WriteString("\nTech: " + strings.Join(project.Keywords, ", ") + "\n")
+			}
+		}
+	}
+
+	if len(r.Interests) > 0 {
+		md.WriteString("\n## Interests\n\n")
+		for _, interest := range r.Interests {
+			line := "- **" + interest.Name + "**"
+			if interest.Summary != "" {
+				line += ": " + interest.Summary
+			}
+			md.WriteString(line + "\n")
+		}
+	}
+
+	return md.String()
+}
+
+func (r *Resume) contactLines() []string {
+	lines := []string{}
+	if r.Basics.Email != "" {
+		lines = append(lines, "Email: "+r.Basics.Email)
+	}
+	if r.Basics.Phone != "" {
+		lines = append(lines, "Phone: "+r.Basics.Phone)
+	}
+	if location := r.Basics.Location.String(); location != "" {
+		lines = append(lines, "Location: "+location)
+	}
+	if r.Basics.URL != "" {
+		lines = append(lines, "Website: "+r.Basics.URL)
+	}
+	for _, profile := range r.Basics.Profiles {
+		lines = append(lines, profile.Network+": "+profile.URL)
+	}
+	return lines
+}
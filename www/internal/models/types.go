@@ -2,13 +2,16 @@ package models
 
 import (
 	"encoding/xml"
+	"fmt"
 	"time"
 )
 
 type PageData struct {
 	Title   string
 	Content string
-	Data    interface{}
+	// Path is the request path, for canonical links
+	Path string
+	Data interface{}
 }
 
 type TemplateData struct {
@@ -17,6 +20,7 @@ type TemplateData struct {
 		Name        string
 		Description string
 		Author      string
+		BaseURL     string
 		Year        int
 	}
 }
@@ -42,9 +46,34 @@ type SitePage struct {
 	Priority   string
 }
 
+// DefaultPolicy is the rate limit policy of routes no other policy claims
+const DefaultPolicy = "default"
+
+// RateLimiterConfig holds the limits of every rate limit policy by name
 type RateLimiterConfig struct {
+	Policies        map[string]RateLimitPolicy
+	CleanupInterval time.Duration
+}
+
+// RateLimitPolicy is the limit applied to one group of routes
+type RateLimitPolicy struct {
+	Algorithm         Algorithm
 	RequestsPerMinute int
 	BurstSize         int
 	WindowSize        time.Duration
-	CleanupInterval   time.Duration
+}
+
+func (c RateLimiterConfig) policy(name string) (RateLimitPolicy, error) {
+	policy, ok := c.Policies[name]
+	if !ok {
+		return RateLimitPolicy{}, fmt.Errorf("ratelimit: unknown policy %q", name)
+	}
+	return policy, nil
+}
+
+// forgetAfter is how long a key must be idle before its state is back to a
+// full budget under any algorithm, so it can be dropped
+func (p RateLimitPolicy) forgetAfter() time.Duration {
+	refill := perToken(p) * time.Duration(p.BurstSize)
+	return max(time.Hour, p.WindowSize, refill)
 }
@@ -0,0 +1,262 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error reply sent by the server
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// ErrNil is returned for nil bulk strings, such as GET on a missing key
+var ErrNil = errors.New("redis: nil reply")
+
+// Client speaks RESP2 to a Redis-compatible server over a small pool of
+// connections. Replies are returned as string, int64, []interface{} or nil,
+// with error replies nested in an array kept as Error items.
+type Client struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+	pool     chan *conn
+}
+
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+}
+
+// NewClient parses a redis://[:password@]host:port[/db] URL. No connection is
+// made until the first command.
+func NewClient(rawURL string, poolSize int, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("redis: unsupported scheme %q", u.Scheme)
+	}
+
+	client := &Client{
+		addr:    u.Host,
+		timeout: timeout,
+		pool:    make(chan *conn, poolSize),
+	}
+	if !strings.Contains(client.addr, ":") {
+		client.addr += ":6379"
+	}
+	if password, ok := u.User.Password(); ok {
+		client.password = password
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		client.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid database %q", db)
+		}
+	}
+
+	return client, nil
+}
+
+// Do sends one command and waits for its reply. A connection that fails is
+// discarded so the next command dials a fresh one.
+func (c *Client) Do(args ...interface{}) (interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(c.timeout, args...)
+	if err != nil {
+		var replyErr Error
+		if !errors.As(err, &replyErr) && !errors.Is(err, ErrNil) {
+			cn.netConn.Close()
+			return nil, err
+		}
+	}
+
+	c.put(cn)
+	return reply, err
+}
+
+func (c *Client) get() (*conn, error) {
+	select {
+	case cn := <-c.pool:
+		return cn, nil
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{netConn: netConn, reader: bufio.NewReader(netConn)}
+
+	if c.password != "" {
+		if _, err := cn.do(c.timeout, "AUTH", c.password); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do(c.timeout, "SELECT", c.db); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.pool <- cn:
+	default:
+		cn.netConn.Close()
+	}
+}
+
+// Close closes every idle connection
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.pool:
+			cn.netConn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (cn *conn) do(timeout time.Duration, args ...interface{}) (interface{}, error) {
+	if timeout > 0 {
+		cn.netConn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var value string
+		switch v := arg.(type) {
+		case string:
+			value = v
+		case []byte:
+			value = string(v)
+		case int:
+			value = strconv.Itoa(v)
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			value = fmt.Sprint(v)
+		}
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(value), value)
+	}
+
+	if _, err := cn.netConn.Write([]byte(command.String())); err != nil {
+		return nil, err
+	}
+	return cn.read()
+}
+
+func (cn *conn) read() (interface{}, error) {
+	line, err := cn.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, ErrNil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(cn.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, ErrNil
+		}
+		// Error replies inside the array become items, so the rest of the
+		// array is still read and the connection stays usable
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := cn.read()
+			var replyErr Error
+			switch {
+			case errors.As(err, &replyErr):
+				items[i] = replyErr
+			case err != nil && !errors.Is(err, ErrNil):
+				return nil, err
+			default:
+				items[i] = item
+			}
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// Int converts an integer reply
+func Int(reply interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("redis: unexpected %T reply", reply)
+}
+
+// Values converts an array reply
+func Values(reply interface{}, err error) ([]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected %T reply", reply)
+	}
+	return values, nil
+}
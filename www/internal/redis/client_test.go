@@ -0,0 +1,295 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeServer is a RESP server answering each command with the raw reply its
+// handler returns, an empty reply closing the connection
+type fakeServer struct {
+	listener net.Listener
+	handler  func(args []string) string
+	conns    atomic.Int32
+	mutex    sync.Mutex
+	commands [][]string
+}
+
+func newFakeServer(t *testing.T, handler func(args []string) string) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeServer{listener: listener, handler: handler}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.conns.Add(1)
+			go server.serve(netConn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeServer) serve(netConn net.Conn) {
+	defer netConn.Close()
+	reader := bufio.NewReader(netConn)
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.commands = append(s.commands, args)
+		s.mutex.Unlock()
+
+		reply := s.handler(args)
+		if reply == "" {
+			return
+		}
+		if _, err := io.WriteString(netConn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func (s *fakeServer) url() string {
+	return "redis://" + s.listener.Addr().String()
+}
+
+func newTestClient(t *testing.T, rawURL string) *Client {
+	t.Helper()
+	client, err := NewClient(rawURL, 2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    interface{}
+		wantErr error
+		// rest is what must be left unread for the next reply
+		rest string
+	}{
+		{name: "status", input: "+OK\r\n", want: "OK"},
+		{name: "error", input: "-ERR wrong\r\n", wantErr: Error("ERR wrong")},
+		{name: "integer", input: ":-7\r\n", want: int64(-7)},
+		{name: "bulk", input: "$3\r\nabc\r\n", want: "abc"},
+		{name: "empty bulk", input: "$0\r\n\r\n", want: ""},
+		{name: "binary bulk", input: "$4\r\n\r\n\r\n\r\n", want: "\r\n\r\n"},
+		{name: "nil bulk", input: "$-1\r\n", wantErr: ErrNil},
+		{name: "nil array", input: "*-1\r\n", wantErr: ErrNil},
+		{name: "empty array", input: "*0\r\n", want: []interface{}{}},
+		{name: "nil in array", input: "*2\r\n$-1\r\n*-1\r\n", want: []interface{}{nil, nil}},
+		{
+			name:  "nested error",
+			input: "*2\r\n*2\r\n-ERR inner\r\n:1\r\n+after\r\n:5\r\n",
+			want:  []interface{}{[]interface{}{Error("ERR inner"), int64(1)}, "after"},
+			rest:  ":5\r\n",
+		},
+		{name: "followed by another reply", input: "+OK\r\n:1\r\n", want: "OK", rest: ":1\r\n"},
+		{name: "partial line", input: "+OK", wantErr: io.EOF},
+		{name: "partial bulk", input: "$10\r\nabc", wantErr: io.ErrUnexpectedEOF},
+		{name: "partial array", input: "*3\r\n:1\r\n:2\r\n", wantErr: io.EOF},
+		{name: "bad integer", input: ":x\r\n", wantErr: strconv.ErrSyntax},
+		{name: "unknown type", input: "?\r\n", wantErr: errUnexpected},
+		{name: "empty line", input: "\r\n", wantErr: errUnexpected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.input))
+			cn := &conn{reader: reader}
+
+			got, err := cn.read()
+			if tt.wantErr == errUnexpected {
+				if err == nil {
+					t.Fatalf("reply %#v, want an error", got)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reply %#v, want %#v", got, tt.want)
+			}
+
+			if tt.wantErr == nil || errors.As(err, new(Error)) || errors.Is(err, ErrNil) {
+				rest, _ := io.ReadAll(reader)
+				if string(rest) != tt.rest {
+					t.Errorf("left %q unread, want %q", rest, tt.rest)
+				}
+			}
+		})
+	}
+}
+
+// errUnexpected stands for any protocol error in TestRead
+var errUnexpected = errors.New("any error")
+
+func TestClientReplies(t *testing.T) {
+	replies := map[string]string{
+		"status":  "+OK\r\n",
+		"error":   "-ERR wrong\r\n",
+		"integer": ":42\r\n",
+		"bulk":    "$5\r\nhe\r\no\r\n",
+		"nil":     "$-1\r\n",
+		"array":   "*3\r\n$1\r\na\r\n:2\r\n$-1\r\n",
+		"nested":  "*2\r\n*1\r\n+x\r\n*0\r\n",
+		"errors":  "*3\r\n:1\r\n-ERR first\r\n-WRONGTYPE second\r\n",
+	}
+	server := newFakeServer(t, func(args []string) string {
+		return replies[args[0]]
+	})
+	client := newTestClient(t, server.url())
+
+	tests := []struct {
+		command string
+		want    interface{}
+		wantErr error
+	}{
+		{"status", "OK", nil},
+		{"error", nil, Error("ERR wrong")},
+		{"integer", int64(42), nil},
+		{"bulk", "he\r\no", nil},
+		{"nil", nil, ErrNil},
+		{"array", []interface{}{"a", int64(2), nil}, nil},
+		{"nested", []interface{}{[]interface{}{"x"}, []interface{}{}}, nil},
+		{"errors", []interface{}{int64(1), Error("ERR first"), Error("WRONGTYPE second")}, nil},
+		// Run again to check nothing was left unread on the pooled connection
+		{"integer", int64(42), nil},
+		{"status", "OK", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := client.Do(tt.command)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error %v, want %v", tt.command, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: reply %#v, want %#v", tt.command, got, tt.want)
+		}
+	}
+
+	if conns := server.conns.Load(); conns != 1 {
+		t.Errorf("dialed %d connections, want the pooled one reused", conns)
+	}
+}
+
+func TestClientDiscardsBrokenConnection(t *testing.T) {
+	server := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "broken":
+			return "?what\r\n"
+		case "hangup":
+			return ""
+		}
+		return "+PONG\r\n"
+	})
+	client := newTestClient(t, server.url())
+
+	for _, command := range []string{"broken", "hangup"} {
+		if _, err := client.Do(command); err == nil {
+			t.Errorf("%s: no error", command)
+		}
+		if reply, err := client.Do("PING"); err != nil || reply != "PONG" {
+			t.Errorf("PING after %s = %v, %v", command, reply, err)
+		}
+	}
+
+	if conns := server.conns.Load(); conns != 3 {
+		t.Errorf("dialed %d connections, want a fresh one after each failure", conns)
+	}
+}
+
+func TestClientAuthAndSelect(t *testing.T) {
+	server := newFakeServer(t, func(args []string) string {
+		return "+OK\r\n"
+	})
+	client := newTestClient(t, "redis://:secret@"+server.listener.Addr().String()+"/3")
+
+	if _, err := client.Do("PING"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	want := [][]string{{"AUTH", "secret"}, {"SELECT", "3"}, {"PING"}}
+	if !reflect.DeepEqual(server.commands, want) {
+		t.Errorf("commands %q, want %q", server.commands, want)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		url     string
+		addr    string
+		db      int
+		wantErr bool
+	}{
+		{"redis://localhost", "localhost:6379", 0, false},
+		{"redis://cache:6380/2", "cache:6380", 2, false},
+		{"http://localhost", "", 0, true},
+		{"redis://localhost/two", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		client, err := NewClient(tt.url, 1, time.Second)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v", tt.url, err)
+			continue
+		}
+		if err == nil && (client.addr != tt.addr || client.db != tt.db) {
+			t.Errorf("%s: addr %q db %d, want %q db %d", tt.url, client.addr, client.db, tt.addr, tt.db)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0x800a6/www/internal/utils"
+)
+
+// pageSet is a parsed template set made of the shared layouts in templates/
+// plus a single page from html/
+type pageSet struct {
+	tmpl    *template.Template
+	path    string
+	modTime time.Time
+}
+
+// Registry parses each page set once and serves it from memory. In dev mode
+// it polls templates/ and html/ for modifications and re-parses what changed.
+type Registry struct {
+	dev         bool
+	funcs       template.FuncMap
+	sets        map[string]*pageSet
+	layoutsMod  time.Time
+	mutex       sync.RWMutex
+	watchTicker *time.Ticker
+	stopWatch   chan bool
+	onReload    []func()
+	errs        map[string]error
+}
+
+// NewRegistry creates a registry whose templates can call funcs
+func NewRegistry(dev bool, funcs template.FuncMap) *Registry {
+	return &Registry{
+		dev:   dev,
+		funcs: funcs,
+		sets:  make(map[string]*pageSet),
+		errs:  make(map[string]error),
+	}
+}
+
+// Register parses the page set for the given html/ file and caches it
+func (reg *Registry) Register(pages ...string) error {
+	for _, page := range pages {
+		set, err := reg.parsePageSet(page)
+		if err != nil {
+			reg.setErr(page, err)
+			return err
+		}
+
+		reg.mutex.Lock()
+		reg.sets[page] = set
+		delete(reg.errs, page)
+		reg.mutex.Unlock()
+	}
+
+	layoutsMod, err := latestModTime(layoutsGlob())
+	if err != nil {
+		return err
+	}
+
+	reg.mutex.Lock()
+	reg.layoutsMod = layoutsMod
+	reg.mutex.Unlock()
+
+	return nil
+}
+
+// Get returns the parsed template set for a page, parsing it on first use
+// if it was not registered at startup
+func (reg *Registry) Get(page string) (*template.Template, error) {
+	reg.mutex.RLock()
+	set, exists := reg.sets[page]
+	reg.mutex.RUnlock()
+
+	if exists {
+		return set.tmpl, nil
+	}
+
+	if err := reg.Register(page); err != nil {
+		return nil, err
+	}
+
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+	return reg.sets[page].tmpl, nil
+}
+
+// OnReload registers a function called after any page set is re-parsed
+func (reg *Registry) OnReload(fn func()) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	reg.onReload = append(reg.onReload, fn)
+}
+
+func (reg *Registry) reloaded() {
+	reg.mutex.RLock()
+	hooks := reg.onReload
+	reg.mutex.RUnlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+func (reg *Registry) setErr(page string, err error) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	reg.errs[page] = err
+}
+
+// Err reports the pages whose last parse failed. They keep being served from
+// the previous successful parse, if any.
+func (reg *Registry) Err() error {
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+
+	pages := make([]string, 0, len(reg.errs))
+	for page := range reg.errs {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+
+	errs := make([]error, 0, len(pages))
+	for _, page := range pages {
+		errs = append(errs, reg.errs[page])
+	}
+	return errors.Join(errs...)
+}
+
+// ModTime returns the newest modification time of the shared layouts and
+// every registered page
+func (reg *Registry) ModTime() time.Time {
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+
+	latest := reg.layoutsMod
+	for _, set := range reg.sets {
+		if set.modTime.After(latest) {
+			latest = set.modTime
+		}
+	}
+	return latest
+}
+
+// Reload re-parses every registered page set. The previous sets are kept if
+// any of them fails to parse.
+func (reg *Registry) Reload() error {
+	reg.mutex.RLock()
+	pages := make([]string, 0, len(reg.sets))
+	for page := range reg.sets {
+		pages = append(pages, page)
+	}
+	reg.mutex.RUnlock()
+
+	sets := make(map[string]*pageSet, len(pages))
+	for _, page := range pages {
+		set, err := reg.parsePageSet(page)
+		if err != nil {
+			reg.setErr(page, err)
+			return err
+		}
+		sets[page] = set
+	}
+
+	layoutsMod, err := latestModTime(layoutsGlob())
+	if err != nil {
+		return err
+	}
+
+	reg.mutex.Lock()
+	reg.sets = sets
+	reg.layoutsMod = layoutsMod
+	reg.errs = make(map[string]error)
+	reg.mutex.Unlock()
+
+	reg.reloaded()
+	return nil
+}
+
+// Watch starts polling for template changes when the registry is in dev mode
+func (reg *Registry) Watch(interval time.Duration) {
+	if !reg.dev || reg.watchTicker != nil {
+		return
+	}
+
+	reg.watchTicker = time.NewTicker(interval)
+	reg.stopWatch = make(chan bool)
+	go reg.watch()
+}
+
+func (reg *Registry) Stop() {
+	if reg.watchTicker == nil {
+		return
+	}
+	reg.stopWatch <- true
+}
+
+func (reg *Registry) watch() {
+	for {
+		select {
+		case <-reg.watchTicker.C:
+			reg.checkForChanges()
+		case <-reg.stopWatch:
+			reg.watchTicker.Stop()
+			return
+		}
+	}
+}
+
+func (reg *Registry) checkForChanges() {
+	layoutsMod, err := latestModTime(layoutsGlob())
+	if err != nil {
+		log.Printf("templates: %v", err)
+		return
+	}
+
+	reg.mutex.RLock()
+	layoutsChanged := layoutsMod.After(reg.layoutsMod)
+	reg.mutex.RUnlock()
+
+	// Every page set embeds the shared layouts, so a layout change means
+	// re-parsing all of them
+	if layoutsChanged {
+		if err := reg.Reload(); err != nil {
+			log.Printf("templates: reload failed: %v", err)
+			return
+		}
+		log.Println("templates: layouts changed, reloaded all pages")
+		return
+	}
+
+	reg.mutex.RLock()
+	changed := []string{}
+	for page, set := range reg.sets {
+		info, err := os.Stat(set.path)
+		if err != nil || info.ModTime().After(set.modTime) {
+			changed = append(changed, page)
+		}
+	}
+	reg.mutex.RUnlock()
+
+	for _, page := range changed {
+		set, err := reg.parsePageSet(page)
+		if err != nil {
+			reg.setErr(page, err)
+			log.Printf("templates: reload of %s failed: %v", page, err)
+			continue
+		}
+
+		reg.mutex.Lock()
+		reg.sets[page] = set
+		delete(reg.errs, page)
+		reg.mutex.Unlock()
+
+		log.Printf("templates: reloaded %s", page)
+	}
+
+	if len(changed) > 0 {
+		reg.reloaded()
+	}
+}
+
+func (reg *Registry) parsePageSet(page string) (*pageSet, error) {
+	path := utils.GetTemplatePath(filepath.Join("html", page))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("page %s not found: %w", page, err)
+	}
+
+	tmpl, err := template.New("layouts").Funcs(reg.funcs).ParseGlob(layoutsGlob())
+	if err != nil {
+		return nil, fmt.Errorf("parsing layouts: %w", err)
+	}
+
+	tmpl, err = tmpl.ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", page, err)
+	}
+
+	return &pageSet{
+		tmpl:    tmpl,
+		path:    path,
+		modTime: info.ModTime(),
+	}, nil
+}
+
+func layoutsGlob() string {
+	return utils.GetTemplatePath("templates/*.html")
+}
+
+// latestModTime returns the newest modification time among files matching
+// pattern, so added or edited layouts are both picked up
+func latestModTime(pattern string) (time.Time, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var latest time.Time
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
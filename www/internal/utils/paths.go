@@ -12,6 +12,15 @@ func GetTemplatePath(path string) string {
 	return filepath.Join("www", path)
 }
 
+// GetChangelogPath returns the location of CHANGELOG.md, which lives at the
+// repository root in development and next to the binary in builds
+func GetChangelogPath() string {
+	if _, err := os.Stat("CHANGELOG.md"); err == nil {
+		return "CHANGELOG.md"
+	}
+	return filepath.Join("..", "CHANGELOG.md")
+}
+
 func GetPageTitle(path string) string {
 	switch path {
 	case "/":
@@ -0,0 +1,24 @@
+package utils
+
+import "strings"
+
+// WrapText breaks text into lines no longer than width, prefixing each line
+// with indent. Words longer than the available width are kept whole.
+func WrapText(text string, width int, indent string) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{}
+	}
+
+	lines := []string{}
+	line := indent + words[0]
+	for _, word := range words[1:] {
+		if len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = indent + word
+			continue
+		}
+		line += " " + word
+	}
+	return append(lines, line)
+}